@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,6 +12,7 @@ import (
 	"workflow-platform/internal/engine/nodes"
 	"workflow-platform/internal/llm"
 	"workflow-platform/internal/queue"
+	"workflow-platform/internal/worker"
 )
 
 func main() {
@@ -32,13 +33,53 @@ func main() {
 	}
 	defer redisClient.Client.Close()
 
-	// Initialize Handlers
-	wfHandler := api.NewWorkflowHandler(database)
+	// Initialize LLM Client and Node Types
+	providers := llm.NewRegistry(cfg.LLM)
+	llmClient, err := providers.Default()
+	if err != nil {
+		log.Fatalf("Failed to create LLM client: %v", err)
+	}
+	workflowStore := db.NewWorkflowStore(database)
+	registry := nodes.NewRegistry(nodes.Dependencies{
+		LLM:         llmClient,
+		Providers:   providers,
+		Workflows:   workflowStore,
+		Transcripts: redisClient,
+	})
 
-	// Initialize LLM Client
-	llmClient := llm.NewOpenAIClient(cfg.LLM.APIKey, cfg.LLM.Model)
+	// Initialize Handlers
+	wfHandler := api.NewWorkflowHandler(database, registry)
+	jobHandler := api.NewJobHandler(redisClient, registry)
+	nodeTypeHandler := api.NewNodeTypeHandler(registry)
+	runStore := db.NewRunStore(database)
+	runHandler := api.NewRunHandler(runStore)
+
+	// Start Worker Pool
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool := worker.NewPool(redisClient, runStore, cfg.Worker, newExecutor(registry, cfg.Worker))
+	if err := pool.Start(ctx); err != nil {
+		log.Fatalf("Failed to start worker pool: %v", err)
+	}
 
-	http.HandleFunc("/api/execute", enableCors(handleExecute(llmClient)))
+	http.HandleFunc("/api/execute", enableCors(jobHandler.SubmitJob))
+	http.HandleFunc("/api/node-types", enableCors(nodeTypeHandler.ListNodeTypes))
+	http.HandleFunc("/api/validate", enableCors(wfHandler.ValidateWorkflow))
+	http.HandleFunc("/api/jobs", enableCors(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			jobHandler.CancelJob(w, r)
+		} else {
+			jobHandler.GetJob(w, r)
+		}
+	}))
+	http.HandleFunc("/api/runs", enableCors(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("job_id") != "" {
+			runHandler.GetRun(w, r)
+		} else {
+			runHandler.ListRuns(w, r)
+		}
+	}))
 	http.HandleFunc("/api/workflows", enableCors(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
 			wfHandler.SaveWorkflow(w, r)
@@ -73,57 +114,15 @@ func enableCors(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func handleExecute(llmClient llm.Client) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var wf engine.Workflow
-		if err := json.NewDecoder(r.Body).Decode(&wf); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		fmt.Printf("Received workflow: %s with %d nodes\n", wf.ID, len(wf.Nodes))
-
-		// Create ExecutionContext
-		execCtx := engine.NewExecutionContext(wf.ID)
-
-		// Determine which LLM client to use
-		currentLLMClient := llmClient
-		if apiKey, ok := wf.Config["openai_api_key"]; ok && apiKey != "" {
-			fmt.Println("Using API key from frontend request")
-			currentLLMClient = llm.NewOpenAIClient(apiKey, "gpt-4") // Default to gpt-4 or read from config
-		}
-
-		// Define Vertex Factory
-		factory := func(nodeType engine.NodeType) (engine.Vertex, error) {
-			switch nodeType {
-			case engine.NodeTypeLLM:
-				return &nodes.LLMVertex{Client: currentLLMClient}, nil
-			case engine.NodeTypeResult:
-				return &nodes.ResultVertex{}, nil
-			case engine.NodeTypeStart:
-				return &nodes.LLMVertex{Client: currentLLMClient}, nil
-			case engine.NodeTypeTask:
-				return &nodes.LLMVertex{Client: currentLLMClient}, nil
-			default:
-				return nil, fmt.Errorf("unknown node type: %s", nodeType)
-			}
-		}
-
-		// Run BSP Engine Synchronously for now (Migration in progress)
-		if err := engine.ExecuteBSP(wf, execCtx, factory); err != nil {
-			fmt.Printf("Workflow execution failed: %v\n", err)
-			http.Error(w, fmt.Sprintf("Workflow execution failed: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		fmt.Println("Workflow execution completed successfully.")
+// newExecutor returns the function workers use to run a queued workflow. LLM nodes pick up
+// API keys sent with the workflow config themselves.
+func newExecutor(registry *engine.Registry, workerCfg config.WorkerConfig) worker.Executor {
+	return func(ctx context.Context, wf engine.Workflow, execCtx *engine.ExecutionContext, opts engine.BSPOptions) error {
+		fmt.Printf("Executing workflow: %s with %d nodes\n", wf.ID, len(wf.Nodes))
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(execCtx.Results)
+		opts.NumWorkers = workerCfg.NumWorkers
+		// Nodes without their own retry settings; independent of job redeliveries
+		opts.Retry = engine.RetryPolicy{MaxAttempts: workerCfg.NodeMaxAttempts}
+		return engine.ExecuteBSP(ctx, wf, execCtx, registry.Factory(), opts)
 	}
 }
@@ -5,10 +5,10 @@ go 1.24.4
 require (
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/lib/pq v1.10.9
+	github.com/sashabaranov/go-openai v1.41.2
 )
 
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/sashabaranov/go-openai v1.41.2 // indirect
 )
@@ -3,25 +3,29 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"workflow-platform/internal/engine"
 )
 
 type WorkflowHandler struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Registry *engine.Registry
 }
 
-func NewWorkflowHandler(db *sql.DB) *WorkflowHandler {
-	return &WorkflowHandler{DB: db}
+func NewWorkflowHandler(db *sql.DB, registry *engine.Registry) *WorkflowHandler {
+	return &WorkflowHandler{DB: db, Registry: registry}
 }
 
 type SavedWorkflow struct {
 	ID         string          `json:"id"`
 	Name       string          `json:"name"`
 	Definition engine.Workflow `json:"definition"`
+	Version    int             `json:"version"`
 	CreatedAt  time.Time       `json:"created_at"`
 	UpdatedAt  time.Time       `json:"updated_at"`
 }
@@ -45,12 +49,13 @@ func (h *WorkflowHandler) SaveWorkflow(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Printf("Received Save Request: ID=%s, Name=%s, Nodes=%d\n", req.ID, req.Name, len(req.Definition.Nodes))
 
-	// Upsert workflow
+	// Upsert workflow, bumping its version, and keep a copy of every version
 	query := `
 		INSERT INTO workflows (id, name, definition, updated_at)
 		VALUES ($1, $2, $3, NOW())
 		ON CONFLICT (id) DO UPDATE
-		SET name = $2, definition = $3, updated_at = NOW()
+		SET name = $2, definition = $3, version = workflows.version + 1, updated_at = NOW()
+		RETURNING version
 	`
 
 	defJSON, err := json.Marshal(req.Definition)
@@ -59,15 +64,80 @@ func (h *WorkflowHandler) SaveWorkflow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = h.DB.Exec(query, req.ID, req.Name, defJSON)
+	version, err := h.saveVersion(query, req.ID, req.Name, defJSON)
 	if err != nil {
 		fmt.Printf("Error saving workflow: %v\n", err)
 		http.Error(w, "Failed to save workflow", http.StatusInternalServerError)
 		return
 	}
 
+	// Drafts may be saved while incomplete. Their problems are reported with status
+	// "saved_with_issues" and valid=false so that clients can't mistake them for a clean save.
+	resp := map[string]interface{}{"status": "saved", "valid": true, "version": version}
+	var verr *engine.ValidationError
+	if errors.As(engine.Validate(req.Definition, h.Registry), &verr) {
+		resp["status"] = "saved_with_issues"
+		resp["valid"] = false
+		resp["issues"] = verr.Issues
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "saved"})
+	json.NewEncoder(w).Encode(resp)
+}
+
+// saveVersion runs the upsert query and records the resulting version in workflow_versions
+func (h *WorkflowHandler) saveVersion(query, id, name string, defJSON []byte) (int, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRow(query, id, name, defJSON).Scan(&version); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`INSERT INTO workflow_versions (workflow_id, version, definition) VALUES ($1, $2, $3)`, id, version, defJSON); err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// ValidateWorkflow checks a workflow definition without saving or running it
+func (h *WorkflowHandler) ValidateWorkflow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var wf engine.Workflow
+	if err := json.NewDecoder(r.Body).Decode(&wf); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	issues := []engine.ValidationIssue{}
+	var verr *engine.ValidationError
+	if errors.As(engine.Validate(wf, h.Registry), &verr) {
+		issues = verr.Issues
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"valid":  len(issues) == 0,
+		"issues": issues,
+	})
+}
+
+// writeValidationError responds with 400 and the list of validation issues
+func writeValidationError(w http.ResponseWriter, verr *engine.ValidationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  verr.Error(),
+		"issues": verr.Issues,
+	})
 }
 
 func (h *WorkflowHandler) ListWorkflows(w http.ResponseWriter, r *http.Request) {
@@ -76,7 +146,7 @@ func (h *WorkflowHandler) ListWorkflows(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	rows, err := h.DB.Query("SELECT id, name, created_at, updated_at FROM workflows ORDER BY updated_at DESC")
+	rows, err := h.DB.Query("SELECT id, name, version, created_at, updated_at FROM workflows ORDER BY updated_at DESC")
 	if err != nil {
 		http.Error(w, "Failed to list workflows", http.StatusInternalServerError)
 		return
@@ -86,7 +156,7 @@ func (h *WorkflowHandler) ListWorkflows(w http.ResponseWriter, r *http.Request)
 	var workflows []SavedWorkflow
 	for rows.Next() {
 		var wf SavedWorkflow
-		if err := rows.Scan(&wf.ID, &wf.Name, &wf.CreatedAt, &wf.UpdatedAt); err != nil {
+		if err := rows.Scan(&wf.ID, &wf.Name, &wf.Version, &wf.CreatedAt, &wf.UpdatedAt); err != nil {
 			continue
 		}
 		workflows = append(workflows, wf)
@@ -110,8 +180,19 @@ func (h *WorkflowHandler) GetWorkflow(w http.ResponseWriter, r *http.Request) {
 
 	var wf SavedWorkflow
 	var defJSON []byte
-	err := h.DB.QueryRow("SELECT id, name, definition, created_at, updated_at FROM workflows WHERE id = $1", id).
-		Scan(&wf.ID, &wf.Name, &defJSON, &wf.CreatedAt, &wf.UpdatedAt)
+	err := h.DB.QueryRow("SELECT id, name, definition, version, created_at, updated_at FROM workflows WHERE id = $1", id).
+		Scan(&wf.ID, &wf.Name, &defJSON, &wf.Version, &wf.CreatedAt, &wf.UpdatedAt)
+
+	// An older version can be requested with ?version=
+	if v := r.URL.Query().Get("version"); v != "" && err == nil {
+		version, convErr := strconv.Atoi(v)
+		if convErr != nil {
+			http.Error(w, "Invalid version parameter", http.StatusBadRequest)
+			return
+		}
+		err = h.DB.QueryRow("SELECT definition, version, created_at FROM workflow_versions WHERE workflow_id = $1 AND version = $2", id, version).
+			Scan(&defJSON, &wf.Version, &wf.UpdatedAt)
+	}
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Workflow not found", http.StatusNotFound)
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/queue"
+)
+
+type JobHandler struct {
+	Queue    *queue.RedisClient
+	Registry *engine.Registry
+}
+
+func NewJobHandler(q *queue.RedisClient, registry *engine.Registry) *JobHandler {
+	return &JobHandler{Queue: q, Registry: registry}
+}
+
+// SubmitJob enqueues a workflow for asynchronous execution and returns its job ID
+func (h *JobHandler) SubmitJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	wf, provided, err := decodeExecuteRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var verr *engine.ValidationError
+	if errors.As(engine.Validate(wf, h.Registry), &verr) {
+		writeValidationError(w, verr)
+		return
+	}
+
+	inputs, err := engine.ResolveInputs(wf, provided)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job := queue.Job{
+		ID:        queue.NewJobID(),
+		Workflow:  wf,
+		Inputs:    inputs,
+		CreatedAt: time.Now(),
+	}
+
+	fmt.Printf("Queueing workflow %s with %d nodes as job %s\n", wf.ID, len(wf.Nodes), job.ID)
+
+	if err := h.Queue.EnqueueJob(r.Context(), job); err != nil {
+		fmt.Printf("Error enqueueing job: %v\n", err)
+		http.Error(w, "Failed to enqueue job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id":     job.ID,
+		"status":     queue.JobQueued,
+		"created_at": job.CreatedAt,
+	})
+}
+
+// decodeExecuteRequest accepts either {"workflow": {...}, "inputs": {...}} or, for older
+// clients, a bare workflow definition without inputs
+func decodeExecuteRequest(r *http.Request) (engine.Workflow, map[string]interface{}, error) {
+	var wf engine.Workflow
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return wf, nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return wf, nil, err
+	}
+	if _, wrapped := fields["workflow"]; !wrapped {
+		err := json.Unmarshal(body, &wf)
+		return wf, nil, err
+	}
+
+	var req struct {
+		Workflow engine.Workflow        `json:"workflow"`
+		Inputs   map[string]interface{} `json:"inputs"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return wf, nil, err
+	}
+	return req.Workflow, req.Inputs, nil
+}
+
+// GetJob returns the hot state of a job
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Missing id parameter", http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.Queue.GetJobState(r.Context(), id)
+	if errors.Is(err, queue.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// CancelJob asks the worker running a job to abort it. A job that is still queued is
+// marked cancelled right away and skipped once a worker receives it.
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Missing id parameter", http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.Queue.GetJobState(r.Context(), id)
+	if errors.Is(err, queue.ErrJobNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get job", http.StatusInternalServerError)
+		return
+	}
+	if state.Status != queue.JobQueued && state.Status != queue.JobRunning {
+		http.Error(w, fmt.Sprintf("Job is already %s", state.Status), http.StatusConflict)
+		return
+	}
+
+	if err := h.Queue.RequestCancel(r.Context(), id); err != nil {
+		fmt.Printf("Error cancelling job %s: %v\n", id, err)
+		http.Error(w, "Failed to cancel job", http.StatusInternalServerError)
+		return
+	}
+	status := "cancelling"
+	if state.Status == queue.JobQueued {
+		state.Status = queue.JobCancelled
+		status = string(queue.JobCancelled)
+		if err := h.Queue.SaveJobState(r.Context(), state); err != nil {
+			fmt.Printf("Error saving state of cancelled job %s: %v\n", id, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id": id,
+		"status": status,
+	})
+}
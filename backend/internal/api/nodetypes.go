@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"workflow-platform/internal/engine"
+)
+
+type NodeTypeHandler struct {
+	Registry *engine.Registry
+}
+
+func NewNodeTypeHandler(registry *engine.Registry) *NodeTypeHandler {
+	return &NodeTypeHandler{Registry: registry}
+}
+
+// ListNodeTypes returns every registered node type with its display name and data schema
+func (h *NodeTypeHandler) ListNodeTypes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Registry.Specs())
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"workflow-platform/internal/db"
+)
+
+type RunHandler struct {
+	Store *db.RunStore
+}
+
+func NewRunHandler(store *db.RunStore) *RunHandler {
+	return &RunHandler{Store: store}
+}
+
+// ListRuns returns the runs of the workflow given by the workflow_id parameter
+func (h *RunHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workflowID := r.URL.Query().Get("workflow_id")
+	if workflowID == "" {
+		http.Error(w, "Missing workflow_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	runs, err := h.Store.ListRuns(workflowID)
+	if err != nil {
+		fmt.Printf("Error listing runs: %v\n", err)
+		http.Error(w, "Failed to list runs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// GetRun returns a single run together with its full execution history
+func (h *RunHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		http.Error(w, "Missing job_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	run, err := h.Store.GetRun(jobID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		fmt.Printf("Error getting run: %v\n", err)
+		http.Error(w, "Failed to get run", http.StatusInternalServerError)
+		return
+	}
+
+	history, err := h.Store.GetHistory(jobID)
+	if err != nil {
+		fmt.Printf("Error getting run history: %v\n", err)
+		http.Error(w, "Failed to get run history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"run":     run,
+		"history": history,
+	})
+}
@@ -39,14 +39,18 @@ type DatabaseConfig struct {
 
 type WorkerConfig struct {
 	NumWorkers int
+	// MaxRetries bounds how often a job is redelivered after its worker died
 	MaxRetries int
-	JobTimeout time.Duration
+	// NodeMaxAttempts is how often a failing node runs within a superstep unless it sets data.retry
+	NodeMaxAttempts int
+	JobTimeout      time.Duration
 }
 
 type LLMConfig struct {
 	Provider  string
 	APIKey    string
 	Model     string
+	BaseURL   string
 	MaxTokens int
 }
 
@@ -73,14 +77,16 @@ func Load() *Config {
 			MaxIdleConns: getEnvInt("DB_MAX_IDLE_CONNS", 25),
 		},
 		Worker: WorkerConfig{
-			NumWorkers: getEnvInt("WORKER_POOL_SIZE", 10),
-			MaxRetries: getEnvInt("WORKER_MAX_RETRIES", 3),
-			JobTimeout: 5 * time.Minute,
+			NumWorkers:      getEnvInt("WORKER_POOL_SIZE", 10),
+			MaxRetries:      getEnvInt("WORKER_MAX_RETRIES", 3),
+			NodeMaxAttempts: getEnvInt("WORKER_NODE_MAX_ATTEMPTS", 1),
+			JobTimeout:      5 * time.Minute,
 		},
 		LLM: LLMConfig{
 			Provider:  getEnv("LLM_PROVIDER", "openai"),
 			APIKey:    getEnv("LLM_API_KEY", ""),
 			Model:     getEnv("LLM_MODEL", "gpt-4"),
+			BaseURL:   getEnv("LLM_BASE_URL", ""),
 			MaxTokens: getEnvInt("LLM_MAX_TOKENS", 4000),
 		},
 	}
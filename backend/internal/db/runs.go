@@ -0,0 +1,232 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"workflow-platform/internal/engine"
+)
+
+// Run is a single execution of a workflow as stored in workflow_results
+type Run struct {
+	ID          string                 `json:"id"`
+	JobID       string                 `json:"job_id"`
+	WorkflowID  string                 `json:"workflow_id"`
+	Definition  *engine.Workflow       `json:"workflow_definition,omitempty"`
+	Inputs      map[string]interface{} `json:"inputs,omitempty"`
+	Result      map[string]interface{} `json:"result,omitempty"`
+	Outputs     map[string]interface{} `json:"outputs,omitempty"`
+	Status      string                 `json:"status"`
+	Error       string                 `json:"error,omitempty"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+	DurationMS  *int64                 `json:"duration_ms,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+// HistoryEntry is one node execution within a superstep as stored in execution_history
+type HistoryEntry struct {
+	ID         int64       `json:"id"`
+	JobID      string      `json:"job_id"`
+	StepNumber int         `json:"step_number"`
+	StepName   string      `json:"step_name"`
+	Status     string      `json:"status"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Attempts   int         `json:"attempts,omitempty"`
+	ExecutedAt time.Time   `json:"executed_at"`
+}
+
+// RunStore persists workflow runs and their execution history
+type RunStore struct {
+	DB *sql.DB
+}
+
+func NewRunStore(db *sql.DB) *RunStore {
+	return &RunStore{DB: db}
+}
+
+// StartRun inserts the workflow_results row for a run that has just begun.
+// Secret config entries such as API keys are not stored with the definition.
+func (s *RunStore) StartRun(jobID string, wf engine.Workflow, inputs map[string]interface{}, status string, startedAt time.Time) error {
+	wf, _ = engine.SplitSecrets(wf)
+	defJSON, err := json.Marshal(wf)
+	if err != nil {
+		return fmt.Errorf("failed to marshal definition: %w", err)
+	}
+	inputsJSON, err := json.Marshal(inputs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inputs: %w", err)
+	}
+
+	_, err = s.DB.Exec(`
+		INSERT INTO workflow_results (id, job_id, workflow_id, workflow_definition, inputs, status, started_at)
+		VALUES ($1, $1, $2, $3, $4, $5, $6)
+		ON CONFLICT (job_id) DO UPDATE
+		SET status = $5, started_at = $6, completed_at = NULL, duration_ms = NULL, error = NULL
+	`, jobID, wf.ID, defJSON, inputsJSON, status, startedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert run %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// FinishRun records the outcome of a run and its per-node history in a single transaction
+func (s *RunStore) FinishRun(jobID string, status string, results, outputs map[string]interface{}, runErr string, startedAt, completedAt time.Time, history []engine.StepRecord) error {
+	resultJSON, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+	outputsJSON, err := json.Marshal(outputs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outputs: %w", err)
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE workflow_results
+		SET status = $2, result = $3, outputs = $4, error = NULLIF($5, ''), completed_at = $6, duration_ms = $7
+		WHERE job_id = $1
+	`, jobID, status, resultJSON, outputsJSON, runErr, completedAt, completedAt.Sub(startedAt).Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to update run %s: %w", jobID, err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO execution_history (job_id, step_number, step_name, status, result, error, attempts, executed_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, record := range history {
+		var recordJSON interface{} // NULL when the node produced no result
+		if record.Result != nil {
+			data, err := json.Marshal(record.Result)
+			if err != nil {
+				return fmt.Errorf("failed to marshal result of node %s: %w", record.NodeID, err)
+			}
+			recordJSON = data
+		}
+		if _, err := stmt.Exec(jobID, record.Step, record.NodeID, string(record.Status), recordJSON, record.Error, record.Attempts, record.FinishedAt); err != nil {
+			return fmt.Errorf("failed to insert history for node %s: %w", record.NodeID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListRuns returns the runs of a workflow, newest first, without their definitions or results
+func (s *RunStore) ListRuns(workflowID string) ([]Run, error) {
+	rows, err := s.DB.Query(`
+		SELECT job_id, COALESCE(workflow_id, ''), COALESCE(status, ''), COALESCE(error, ''), started_at, completed_at, duration_ms, created_at
+		FROM workflow_results
+		WHERE workflow_id = $1
+		ORDER BY started_at DESC
+	`, workflowID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		var run Run
+		var durationMS sql.NullInt64
+		if err := rows.Scan(&run.JobID, &run.WorkflowID, &run.Status, &run.Error, &run.StartedAt, &run.CompletedAt, &durationMS, &run.CreatedAt); err != nil {
+			return nil, err
+		}
+		run.ID = run.JobID
+		if durationMS.Valid {
+			run.DurationMS = &durationMS.Int64
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetRun loads a run with its definition and results. It returns sql.ErrNoRows if the run doesn't exist.
+func (s *RunStore) GetRun(jobID string) (*Run, error) {
+	var run Run
+	var defJSON, inputsJSON, resultJSON, outputsJSON []byte
+	var durationMS sql.NullInt64
+	err := s.DB.QueryRow(`
+		SELECT id, job_id, COALESCE(workflow_id, ''), workflow_definition, inputs, result, outputs, COALESCE(status, ''), COALESCE(error, ''),
+			started_at, completed_at, duration_ms, created_at
+		FROM workflow_results
+		WHERE job_id = $1
+	`, jobID).Scan(&run.ID, &run.JobID, &run.WorkflowID, &defJSON, &inputsJSON, &resultJSON, &outputsJSON, &run.Status, &run.Error,
+		&run.StartedAt, &run.CompletedAt, &durationMS, &run.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if durationMS.Valid {
+		run.DurationMS = &durationMS.Int64
+	}
+	if defJSON != nil {
+		var def engine.Workflow
+		if err := json.Unmarshal(defJSON, &def); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal definition: %w", err)
+		}
+		// Rows written before secrets were stripped on insert may still contain them
+		def, _ = engine.SplitSecrets(def)
+		run.Definition = &def
+	}
+	for _, field := range []struct {
+		name string
+		data []byte
+		dest *map[string]interface{}
+	}{
+		{"inputs", inputsJSON, &run.Inputs},
+		{"result", resultJSON, &run.Result},
+		{"outputs", outputsJSON, &run.Outputs},
+	} {
+		if field.data == nil {
+			continue
+		}
+		if err := json.Unmarshal(field.data, field.dest); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %w", field.name, err)
+		}
+	}
+	return &run, nil
+}
+
+// GetHistory returns the per-node execution history of a run in execution order
+func (s *RunStore) GetHistory(jobID string) ([]HistoryEntry, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, job_id, COALESCE(step_number, 0), COALESCE(step_name, ''), COALESCE(status, ''), result, COALESCE(error, ''), COALESCE(attempts, 1), executed_at
+		FROM execution_history
+		WHERE job_id = $1
+		ORDER BY step_number, id
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		var resultJSON []byte
+		if err := rows.Scan(&entry.ID, &entry.JobID, &entry.StepNumber, &entry.StepName, &entry.Status, &resultJSON, &entry.Error, &entry.Attempts, &entry.ExecutedAt); err != nil {
+			return nil, err
+		}
+		if resultJSON != nil {
+			if err := json.Unmarshal(resultJSON, &entry.Result); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal history result: %w", err)
+			}
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"workflow-platform/internal/engine"
+)
+
+// WorkflowStore reads saved workflow definitions and their versions
+type WorkflowStore struct {
+	DB *sql.DB
+}
+
+func NewWorkflowStore(db *sql.DB) *WorkflowStore {
+	return &WorkflowStore{DB: db}
+}
+
+// LoadWorkflow returns the given version of a saved workflow, or its latest version if version
+// is 0, together with the version number. It returns sql.ErrNoRows if there is no such workflow.
+func (s *WorkflowStore) LoadWorkflow(ctx context.Context, id string, version int) (*engine.Workflow, int, error) {
+	var defJSON []byte
+	var err error
+	if version == 0 {
+		err = s.DB.QueryRowContext(ctx, `SELECT definition, version FROM workflows WHERE id = $1`, id).
+			Scan(&defJSON, &version)
+	} else {
+		err = s.DB.QueryRowContext(ctx, `SELECT definition FROM workflow_versions WHERE workflow_id = $1 AND version = $2`, id, version).
+			Scan(&defJSON)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var wf engine.Workflow
+	if err := json.Unmarshal(defJSON, &wf); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal definition of workflow %s: %w", id, err)
+	}
+	// The editor doesn't always store the ID inside the definition
+	wf.ID = id
+	return &wf, version, nil
+}
@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Built-in aggregator types
+const (
+	AggregateSum    = "sum"
+	AggregateMin    = "min"
+	AggregateMax    = "max"
+	AggregateAppend = "append"
+)
+
+// Aggregator combines the values vertices contribute during a superstep into one value that
+// every vertex can read in the next superstep
+type Aggregator interface {
+	// Combine folds value into acc. acc is nil for the first value of a superstep
+	// (or of the run, for persistent aggregators).
+	Combine(acc, value interface{}) (interface{}, error)
+}
+
+// AggregatorFunc adapts a function to the Aggregator interface
+type AggregatorFunc func(acc, value interface{}) (interface{}, error)
+
+func (f AggregatorFunc) Combine(acc, value interface{}) (interface{}, error) {
+	return f(acc, value)
+}
+
+// AggregatorSpec declares an aggregator of a workflow
+type AggregatorSpec struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // sum, min, max, append or a type added with RegisterAggregator
+	// Persistent aggregators keep accumulating across supersteps instead of starting over
+	Persistent bool `json:"persistent,omitempty"`
+}
+
+var (
+	aggregatorsMu sync.RWMutex
+	aggregators   = map[string]Aggregator{
+		AggregateSum: AggregatorFunc(func(acc, value interface{}) (interface{}, error) {
+			v, ok := toFloat(value)
+			if !ok {
+				return nil, fmt.Errorf("sum needs numbers, got %T", value)
+			}
+			total, _ := toFloat(acc)
+			return total + v, nil
+		}),
+		AggregateMin: AggregatorFunc(func(acc, value interface{}) (interface{}, error) {
+			return compareAggregate(acc, value, func(a, b float64) bool { return b < a })
+		}),
+		AggregateMax: AggregatorFunc(func(acc, value interface{}) (interface{}, error) {
+			return compareAggregate(acc, value, func(a, b float64) bool { return b > a })
+		}),
+		AggregateAppend: AggregatorFunc(func(acc, value interface{}) (interface{}, error) {
+			list, _ := acc.([]interface{})
+			return append(list, value), nil
+		}),
+	}
+)
+
+// RegisterAggregator adds a custom aggregator type that workflows can declare. It is meant to be
+// called during initialization and returns an error if the type already exists.
+func RegisterAggregator(name string, agg Aggregator) error {
+	aggregatorsMu.Lock()
+	defer aggregatorsMu.Unlock()
+	if _, exists := aggregators[name]; exists {
+		return fmt.Errorf("aggregator type %q is already registered", name)
+	}
+	aggregators[name] = agg
+	return nil
+}
+
+func lookupAggregator(name string) (Aggregator, bool) {
+	aggregatorsMu.RLock()
+	defer aggregatorsMu.RUnlock()
+	agg, ok := aggregators[name]
+	return agg, ok
+}
+
+func compareAggregate(acc, value interface{}, better func(a, b float64) bool) (interface{}, error) {
+	v, ok := toFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("expected a number, got %T", value)
+	}
+	current, ok := toFloat(acc)
+	if acc == nil || !ok || better(current, v) {
+		return v, nil
+	}
+	return current, nil
+}
+
+// contribution is a value a vertex passed to Context.Aggregate
+type contribution struct {
+	name  string
+	value interface{}
+}
+
+// Aggregate contributes value to the named aggregator. The combined value is visible to all
+// vertices in the next superstep through Aggregated.
+func (c *Context) Aggregate(name string, value interface{}) {
+	c.contributions = append(c.contributions, contribution{name: name, value: value})
+}
+
+// Aggregated returns the value of an aggregator as of the end of the previous superstep
+func (c *Context) Aggregated(name string) (interface{}, bool) {
+	return c.Execution.Aggregated(name)
+}
+
+// Aggregates returns the values of all aggregators as of the end of the previous superstep
+func (c *Context) Aggregates() map[string]interface{} {
+	return c.Execution.aggregateSnapshot()
+}
+
+// Aggregated returns the current value of an aggregator. Safe for concurrent use.
+func (e *ExecutionContext) Aggregated(name string) (interface{}, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	value, ok := e.Aggregates[name]
+	return value, ok
+}
+
+// aggregateSnapshot returns a copy of all aggregator values. Safe for concurrent use.
+func (e *ExecutionContext) aggregateSnapshot() map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	values := make(map[string]interface{}, len(e.Aggregates))
+	for k, v := range e.Aggregates {
+		values[k] = v
+	}
+	return values
+}
+
+// applyAggregates combines the contributions of a superstep, in the order of active, into the
+// execution context. Non-persistent aggregators without contributions are reset.
+func applyAggregates(wf *Workflow, execCtx *ExecutionContext, active []string, results map[string]vertexResult) error {
+	if len(wf.Aggregators) == 0 {
+		return nil
+	}
+	specs := make(map[string]AggregatorSpec, len(wf.Aggregators))
+	for _, spec := range wf.Aggregators {
+		specs[spec.Name] = spec
+	}
+
+	current := execCtx.aggregateSnapshot()
+	next := make(map[string]interface{}, len(specs))
+	for name, spec := range specs {
+		if spec.Persistent {
+			next[name] = current[name]
+		} else {
+			next[name] = nil
+		}
+	}
+
+	for _, id := range active {
+		for _, c := range results[id].contributions {
+			spec, ok := specs[c.name]
+			if !ok {
+				return fmt.Errorf("node %s contributed to undeclared aggregator %q", id, c.name)
+			}
+			agg, ok := lookupAggregator(spec.Type)
+			if !ok {
+				return fmt.Errorf("aggregator %q has unknown type %q", spec.Name, spec.Type)
+			}
+			value, err := agg.Combine(next[c.name], c.value)
+			if err != nil {
+				return fmt.Errorf("aggregator %q: node %s: %w", c.name, id, err)
+			}
+			next[c.name] = value
+		}
+	}
+
+	execCtx.mu.Lock()
+	execCtx.Aggregates = next
+	execCtx.mu.Unlock()
+	return nil
+}
+
+// contributeFromData handles data.aggregate, a list of {"aggregator": name, "field": path}
+// entries that contribute fields of the node's result without any code in the vertex
+func contributeFromData(ctx *Context) {
+	node := ctx.Node()
+	if node == nil {
+		return
+	}
+	entries, _ := node.Data["aggregate"].([]interface{})
+	if len(entries) == 0 {
+		return
+	}
+	result, _ := ctx.Execution.GetResult(ctx.NodeID)
+	output, _ := result.(map[string]interface{})
+	for _, entry := range entries {
+		m, _ := entry.(map[string]interface{})
+		name, _ := m["aggregator"].(string)
+		field, _ := m["field"].(string)
+		if field == "" {
+			field = "result"
+		}
+		if value, ok := lookupPath(output, field); ok && name != "" {
+			ctx.Aggregate(name, value)
+		}
+	}
+}
+
+// validateAggregators checks the workflow's aggregator declarations and the nodes that contribute to them
+func validateAggregators(wf Workflow) []string {
+	var msgs []string
+	declared := map[string]bool{}
+	for i, spec := range wf.Aggregators {
+		switch {
+		case !paramNamePattern.MatchString(spec.Name):
+			msgs = append(msgs, fmt.Sprintf("aggregator #%d has invalid name %q", i, spec.Name))
+		case declared[spec.Name]:
+			msgs = append(msgs, fmt.Sprintf("duplicate aggregator %q", spec.Name))
+		}
+		declared[spec.Name] = true
+		if _, ok := lookupAggregator(spec.Type); !ok {
+			msgs = append(msgs, fmt.Sprintf("aggregator %q has unknown type %q", spec.Name, spec.Type))
+		}
+	}
+
+	for _, node := range wf.Nodes {
+		entries, _ := node.Data["aggregate"].([]interface{})
+		for _, entry := range entries {
+			m, _ := entry.(map[string]interface{})
+			name, _ := m["aggregator"].(string)
+			if !declared[name] {
+				msgs = append(msgs, fmt.Sprintf("node %q contributes to undeclared aggregator %q", node.ID, name))
+			}
+		}
+		if _, err := DecodeCondition(node.Data["halt_when"]); err != nil {
+			msgs = append(msgs, fmt.Sprintf("node %q: halt_when: %v", node.ID, err))
+		}
+	}
+	return msgs
+}
+
+// StayActive keeps the vertex active after this superstep, so it computes again in the next one
+// even without messages, until it calls VoteToHalt. Nodes can also opt in with data.stay_active.
+func (c *Context) StayActive() {
+	c.stayActive = true
+}
+
+// VoteToHalt deactivates the vertex after this superstep. A halted vertex is reactivated when it
+// receives a message. Vertices that never called StayActive halt after every superstep anyway.
+func (c *Context) VoteToHalt() {
+	c.halted = true
+}
+
+// remainsActive decides whether a vertex that just computed stays active. A node with
+// data.halt_when halts as soon as the condition matches its result, whose "aggregates" field
+// holds the aggregator values after this superstep.
+func remainsActive(node *Node, r vertexResult, execCtx *ExecutionContext) (bool, error) {
+	stay, _ := node.Data["stay_active"].(bool)
+	if r.halted || !(stay || r.stayActive) {
+		return false, nil
+	}
+
+	cond, err := DecodeCondition(node.Data["halt_when"])
+	if err != nil || cond == nil {
+		return true, err
+	}
+	output := map[string]interface{}{}
+	if result, ok := execCtx.GetResult(node.ID); ok {
+		if m, ok := result.(map[string]interface{}); ok {
+			for k, v := range m {
+				output[k] = v
+			}
+		}
+	}
+	output["aggregates"] = execCtx.aggregateSnapshot()
+	halt, err := cond.Evaluate(output)
+	if err != nil {
+		return false, fmt.Errorf("halt_when: %w", err)
+	}
+	return !halt, nil
+}
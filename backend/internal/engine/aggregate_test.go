@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAggregatorsCombineEachSuperstep(t *testing.T) {
+	wf := Workflow{
+		ID: "wf",
+		Aggregators: []AggregatorSpec{
+			{Name: "total", Type: AggregateSum},
+			{Name: "best", Type: AggregateMax},
+			{Name: "worst", Type: AggregateMin},
+			{Name: "seen", Type: AggregateAppend},
+			{Name: "computes", Type: AggregateSum, Persistent: true},
+		},
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("a", NodeTypeTask, nil),
+			node("b", NodeTypeTask, nil),
+			node("c", NodeTypeTask, nil),
+			node("reader", NodeTypeTask, nil),
+		},
+		Edges: []Edge{edge("start", "a"), edge("start", "b"), edge("start", "c"), edge("a", "reader")},
+	}
+	scores := map[string]float64{"a": 2, "b": 5, "c": 1}
+	score := func(ctx *Context, messages []Message) error {
+		ctx.Aggregate("total", scores[ctx.NodeID])
+		ctx.Aggregate("best", scores[ctx.NodeID])
+		ctx.Aggregate("worst", scores[ctx.NodeID])
+		ctx.Aggregate("seen", ctx.NodeID)
+		ctx.Aggregate("computes", 1)
+		return forward(ctx, messages)
+	}
+	var seenByReader map[string]interface{}
+	run := newTestRun(map[string]computeFunc{
+		"a": score, "b": score, "c": score,
+		"reader": func(ctx *Context, messages []Message) error {
+			seenByReader = ctx.Aggregates()
+			ctx.Aggregate("computes", 1)
+			return forward(ctx, messages)
+		},
+	})
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{NumWorkers: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{"total": 8.0, "best": 5.0, "worst": 1.0, "seen": []interface{}{"a", "b", "c"}, "computes": 3.0}
+	if !reflect.DeepEqual(seenByReader, want) {
+		t.Errorf("expected the next superstep to see %v, got %v", want, seenByReader)
+	}
+	// Only the persistent aggregator keeps its value through the reader's superstep
+	want = map[string]interface{}{"total": nil, "best": nil, "worst": nil, "seen": nil, "computes": 4.0}
+	if !reflect.DeepEqual(execCtx.Aggregates, want) {
+		t.Errorf("expected the final aggregates %v, got %v", want, execCtx.Aggregates)
+	}
+}
+
+func TestAggregateFromNodeData(t *testing.T) {
+	wf := Workflow{
+		ID:          "wf",
+		Aggregators: []AggregatorSpec{{Name: "total", Type: AggregateSum, Persistent: true}},
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("a", NodeTypeTask, map[string]interface{}{"aggregate": []interface{}{map[string]interface{}{"aggregator": "total", "field": "meta.score"}}}),
+		},
+		Edges: []Edge{edge("start", "a")},
+	}
+	run := newTestRun(map[string]computeFunc{
+		"a": func(ctx *Context, messages []Message) error {
+			ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{"meta": map[string]interface{}{"score": 7.0}})
+			return nil
+		},
+	})
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := execCtx.Aggregated("total"); got != 7.0 {
+		t.Errorf("expected the node's score to be aggregated, got %v", got)
+	}
+}
+
+func TestAggregatorErrorsFailTheRun(t *testing.T) {
+	tests := []struct {
+		name, want string
+		value      interface{}
+	}{
+		{"undeclared", "undeclared aggregator", 1.0},
+		{"total", "sum needs numbers", "many"},
+	}
+	for _, tt := range tests {
+		wf := Workflow{
+			ID:          "wf",
+			Aggregators: []AggregatorSpec{{Name: "total", Type: AggregateSum}},
+			Nodes:       []Node{node("start", NodeTypeStart, nil)},
+		}
+		run := newTestRun(map[string]computeFunc{
+			"start": func(ctx *Context, messages []Message) error {
+				ctx.Aggregate(tt.name, tt.value)
+				return nil
+			},
+		})
+		err := ExecuteBSP(context.Background(), wf, NewExecutionContext(wf.ID), run.factory(), BSPOptions{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestVertexComputesUntilItVotesToHalt(t *testing.T) {
+	wf := Workflow{ID: "wf", Nodes: []Node{node("start", NodeTypeStart, nil), node("counter", NodeTypeTask, nil)}, Edges: []Edge{edge("start", "counter")}}
+	run := newTestRun(map[string]computeFunc{})
+	run.behavior["counter"] = func(ctx *Context, messages []Message) error {
+		if run.count("counter") == 3 {
+			ctx.VoteToHalt()
+		} else {
+			ctx.StayActive()
+		}
+		return nil
+	}
+
+	if err := ExecuteBSP(context.Background(), wf, NewExecutionContext(wf.ID), run.factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := run.count("counter"); n != 3 {
+		t.Errorf("expected the counter to compute 3 times, got %d", n)
+	}
+	if got := run.received["counter"]; len(got[1]) != 0 || len(got[2]) != 0 {
+		t.Errorf("expected the later computes to run without messages, got %v", got)
+	}
+}
+
+func TestHaltWhenStopsActiveVertex(t *testing.T) {
+	wf := Workflow{
+		ID:          "wf",
+		Aggregators: []AggregatorSpec{{Name: "ticks", Type: AggregateSum, Persistent: true}},
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("ticker", NodeTypeTask, map[string]interface{}{
+				"stay_active": true,
+				"halt_when":   map[string]interface{}{"field": "aggregates.ticks", "operator": OpGreaterEq, "value": 4},
+			}),
+		},
+		Edges: []Edge{edge("start", "ticker")},
+	}
+	run := newTestRun(map[string]computeFunc{
+		"ticker": func(ctx *Context, messages []Message) error {
+			ctx.Aggregate("ticks", 1)
+			return nil
+		},
+	})
+
+	if err := ExecuteBSP(context.Background(), wf, NewExecutionContext(wf.ID), run.factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := run.count("ticker"); n != 4 {
+		t.Errorf("expected the ticker to halt after 4 computes, got %d", n)
+	}
+}
+
+func TestRegisterAggregator(t *testing.T) {
+	longest := AggregatorFunc(func(acc, value interface{}) (interface{}, error) {
+		if s, _ := acc.(string); len(s) >= len(value.(string)) {
+			return s, nil
+		}
+		return value, nil
+	})
+	if err := RegisterAggregator("test_longest", longest); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterAggregator("test_longest", longest); err == nil {
+		t.Error("expected registering a type twice to fail")
+	}
+	if err := RegisterAggregator(AggregateSum, longest); err == nil {
+		t.Error("expected replacing a built-in type to fail")
+	}
+
+	wf := Workflow{
+		ID:          "wf",
+		Aggregators: []AggregatorSpec{{Name: "longest", Type: "test_longest"}},
+		Nodes:       []Node{node("a", NodeTypeStart, nil), node("b", NodeTypeStart, nil)},
+	}
+	words := map[string]string{"a": "go", "b": "gopher"}
+	run := newTestRun(map[string]computeFunc{})
+	for id := range words {
+		run.behavior[id] = func(ctx *Context, messages []Message) error {
+			ctx.Aggregate("longest", words[ctx.NodeID])
+			return nil
+		}
+	}
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := execCtx.Aggregated("longest"); got != "gopher" {
+		t.Errorf("expected the custom aggregator's value, got %v", got)
+	}
+}
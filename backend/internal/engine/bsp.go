@@ -1,16 +1,30 @@
 package engine
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
 // Context provides access to the runtime environment for a vertex
 type Context struct {
+	// Ctx is cancelled when the run is aborted or exceeds its deadline.
+	// Long-running vertices should pass it to any blocking calls.
+	Ctx       context.Context
 	Step      int
 	NodeID    string
 	Workflow  *Workflow
 	Execution *ExecutionContext
 	Outbox    []Message
+
+	completed     bool
+	stayActive    bool
+	halted        bool
+	contributions []contribution
+	run           *runInfo
 }
 
 // SendMessage queues a message to be sent to another vertex in the next superstep
@@ -22,30 +36,136 @@ func (c *Context) SendMessage(to string, content map[string]interface{}) {
 	})
 }
 
+// SendToChildren sends content along every outgoing edge whose condition matches it
+func (c *Context) SendToChildren(content map[string]interface{}) error {
+	return c.sendAlong(content, false)
+}
+
+// SendToFirstMatch sends content along the first outgoing conditional edge that matches it,
+// plus any unconditional edges. The "else" edges are used when nothing matched.
+func (c *Context) SendToFirstMatch(content map[string]interface{}) error {
+	return c.sendAlong(content, true)
+}
+
+func (c *Context) sendAlong(content map[string]interface{}, firstOnly bool) error {
+	edges, err := MatchingEdges(c.Workflow, c.NodeID, content, firstOnly)
+	if err != nil {
+		return err
+	}
+	for _, edge := range edges {
+		msg := make(map[string]interface{}, len(content))
+		for k, v := range content {
+			msg[k] = v
+		}
+		c.SendMessage(edge.Target, msg)
+	}
+	return nil
+}
+
+// Node returns the definition of the node being computed, or nil if it isn't part of the workflow
+func (c *Context) Node() *Node {
+	return nodeByID(c.Workflow, c.NodeID)
+}
+
+func nodeByID(wf *Workflow, id string) *Node {
+	for i := range wf.Nodes {
+		if wf.Nodes[i].ID == id {
+			return &wf.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Complete ends the run after the current superstep. Messages still pending at that point are
+// dropped and their target nodes are marked SKIPPED.
+func (c *Context) Complete() {
+	c.completed = true
+}
+
 // Vertex defines the interface that all node types must implement
 type Vertex interface {
 	// Compute is called in each superstep.
 	// messages: Messages sent to this vertex in the previous superstep.
-	// Returns error if execution fails.
+	// Returns error if execution fails. A failed call may be retried; the node's result and
+	// state are restored before each retry, but effects outside the ExecutionContext are not.
 	Compute(ctx *Context, messages []Message) error
 }
 
-// VertexFactory creates Vertex instances based on NodeType
-type VertexFactory func(nodeType NodeType) (Vertex, error)
+// VertexFactory creates the Vertex that executes a node
+type VertexFactory func(node Node) (Vertex, error)
+
+// BSPOptions tunes how ExecuteBSP schedules work
+type BSPOptions struct {
+	// NumWorkers bounds how many vertices compute concurrently within a superstep.
+	// Values below 1 are treated as 1.
+	NumWorkers int
+
+	// Checkpointer, if set, receives a checkpoint at every superstep barrier.
+	Checkpointer Checkpointer
 
-// ExecuteBSP runs the workflow using the Bulk Synchronous Parallel model
-func ExecuteBSP(wf Workflow, execCtx *ExecutionContext, factory VertexFactory) error {
+	// Resume, if set, continues a previous run from this checkpoint instead of triggering source nodes.
+	Resume *Checkpoint
+
+	// Retry is the retry policy of nodes that don't set data.retry. The zero value runs each node once.
+	Retry RetryPolicy
+
+	// MaxDepth bounds how deeply sub-workflows can nest, counting the outermost run.
+	// Values below 1 use DefaultMaxWorkflowDepth.
+	MaxDepth int
+
+	// Observers receive the events of the run, including those of its sub-workflows.
+	Observers Observers
+
+	// parents are the IDs of the workflows that started this one as a sub-workflow, outermost first
+	parents []string
+}
+
+// vertexResult is the outcome of a single Compute call within a superstep
+type vertexResult struct {
+	outbox        []Message
+	completed     bool
+	stayActive    bool
+	halted        bool
+	contributions []contribution
+	err           error
+}
+
+// ExecuteBSP runs the workflow using the Bulk Synchronous Parallel model.
+// Source nodes are triggered with execCtx.Inputs, and the declared outputs are
+// collected into execCtx.Outputs once the run finishes. Cancelling ctx aborts
+// the run between supersteps and is propagated to running vertices.
+func ExecuteBSP(ctx context.Context, wf Workflow, execCtx *ExecutionContext, factory VertexFactory, opts BSPOptions) (err error) {
 	fmt.Printf("Starting BSP execution for workflow: %s\n", wf.ID)
+	startedAt := time.Now()
+	step := 0
+	opts.Observers.emit(wf.ID, Event{Type: EventRunStarted})
+	defer func() {
+		opts.Observers.emit(wf.ID, Event{Type: EventRunFinished, Step: step, Duration: time.Since(startedAt), Error: errorString(err)})
+	}()
 
 	// 1. Initialize Vertices
 	vertices := make(map[string]Vertex)
 	for _, node := range wf.Nodes {
-		v, err := factory(node.Type)
+		v, err := factory(node)
 		if err != nil {
 			return fmt.Errorf("failed to create vertex for node %s: %w", node.ID, err)
 		}
 		vertices[node.ID] = v
 	}
+	policies, err := retryPolicies(&wf, opts.Retry)
+	if err != nil {
+		return err
+	}
+	combiners, err := nodeCombiners(&wf, vertices)
+	if err != nil {
+		return err
+	}
+	run := &runInfo{
+		factory:  factory,
+		opts:     opts,
+		stack:    append(append([]string(nil), opts.parents...), wf.ID),
+		policies: policies,
+	}
 
 	// 2. Initialize Messages (Step 0: Source nodes receive a trigger message)
 	// Calculate in-degrees
@@ -58,70 +178,339 @@ func ExecuteBSP(wf Workflow, execCtx *ExecutionContext, factory VertexFactory) e
 	}
 
 	inbox := make(map[string][]Message)
-	for _, node := range wf.Nodes {
-		// Trigger if it's a START node OR it has no incoming edges (and isn't a Result/End node)
-		isSource := inDegree[node.ID] == 0
-		if node.Type == NodeTypeStart || (isSource && node.Type != NodeTypeResult && node.Type != NodeTypeEnd) {
-			fmt.Printf("Triggering node %s (Type: %s, In-Degree: %d)\n", node.ID, node.Type, inDegree[node.ID])
-			inbox[node.ID] = append(inbox[node.ID], Message{
-				From:    "system",
-				To:      node.ID,
-				Content: map[string]interface{}{"type": "trigger"},
-			})
+	joins := newJoinGate(&wf, nil)
+	awake := make(map[string]bool)
+	if opts.Resume != nil {
+		fmt.Printf("Resuming workflow %s at superstep %d\n", wf.ID, opts.Resume.Step)
+		execCtx.restore(opts.Resume)
+		inbox = opts.Resume.Inbox
+		step = opts.Resume.Step
+		joins = newJoinGate(&wf, opts.Resume.Joins)
+		for _, id := range opts.Resume.Awake {
+			awake[id] = true
+		}
+	} else {
+		tools := toolNodes(&wf)
+		for _, node := range wf.Nodes {
+			// Trigger if it's a START node OR it has no incoming edges (and isn't a Result/End node)
+			if _, isTool := tools[node.ID]; !isTool && isTriggered(&node, inDegree[node.ID]) {
+				fmt.Printf("Triggering node %s (Type: %s, In-Degree: %d)\n", node.ID, node.Type, inDegree[node.ID])
+				inbox[node.ID] = append(inbox[node.ID], Message{
+					From:    "system",
+					To:      node.ID,
+					Content: map[string]interface{}{"type": "trigger", "inputs": execCtx.Inputs},
+				})
+			}
 		}
 	}
 
-	step := 0
-	maxSteps := 100 // Safety limit
+	maxSteps := wf.MaxSupersteps // Safety limit
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSupersteps
+	}
 
 	for step < maxSteps {
-		activeVertices := 0
-		nextInbox := make(map[string][]Message)
-
 		fmt.Printf("--- Superstep %d ---\n", step)
 
-		// 3. Compute Phase
-		for id, vertex := range vertices {
-			msgs := inbox[id]
+		if err := ctx.Err(); err != nil {
+			markAborted(execCtx, inbox, err)
+			return fmt.Errorf("execution aborted before superstep %d: %w", step, err)
+		}
 
-			// Optimization: Only compute if there are messages or it's the first step for some
-			// In pure Pregel, vertices can "vote to halt". Here, we'll simplify:
-			// If no messages, skip (unless it's a source node in step 0, handled above).
-			if len(msgs) == 0 {
+		// A vertex computes if it received messages or stayed active instead of voting to halt
+		active := make([]string, 0, len(inbox))
+		for id, msgs := range inbox {
+			if _, ok := vertices[id]; ok && len(msgs) > 0 {
+				active = append(active, id)
+			}
+		}
+		for id := range awake {
+			if len(inbox[id]) == 0 {
+				active = append(active, id)
+			}
+		}
+		if len(active) == 0 {
+			// Nodes still waiting for parents that can no longer deliver compute with what they have
+			released := make(map[string][]Message)
+			if joins.flush(released) {
+				for id, msgs := range released {
+					inbox[id] = append(inbox[id], msgs...)
+				}
+				emitRouted(opts.Observers, wf.ID, step-1, released)
 				continue
 			}
+			fmt.Println("No active vertices. Execution finished.")
+			break
+		}
+		sort.Strings(active)
+		opts.Observers.emit(wf.ID, Event{Type: EventSuperstepStarted, Step: step})
 
-			ctx := &Context{
-				Step:      step,
-				NodeID:    id,
-				Workflow:  &wf,
-				Execution: execCtx,
-				Outbox:    make([]Message, 0),
-			}
+		// 3. Compute Phase
+		results := computeSuperstep(ctx, step, active, vertices, inbox, &wf, execCtx, run)
 
-			activeVertices++
-			if err := vertex.Compute(ctx, msgs); err != nil {
+		// Barrier: every vertex of this superstep has finished before anything is routed
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("execution aborted in superstep %d: %w", step, err)
+		}
+		for _, id := range active {
+			if err := results[id].err; err != nil {
+				return fmt.Errorf("error in superstep %d at node %s: %w", step, id, err)
+			}
+		}
+		if err := applyAggregates(&wf, execCtx, active, results); err != nil {
+			return fmt.Errorf("error in superstep %d: %w", step, err)
+		}
+		for _, id := range active {
+			stay, err := remainsActive(nodeByID(&wf, id), results[id], execCtx)
+			if err != nil {
 				return fmt.Errorf("error in superstep %d at node %s: %w", step, id, err)
 			}
+			if stay {
+				awake[id] = true
+			} else {
+				delete(awake, id)
+			}
+		}
 
-			// 4. Communication Phase (Route messages)
-			for _, msg := range ctx.Outbox {
+		// 4. Communication Phase (Route messages)
+		nextInbox := make(map[string][]Message)
+		completedBy := ""
+		for _, id := range active {
+			for _, msg := range results[id].outbox {
 				nextInbox[msg.To] = append(nextInbox[msg.To], msg)
 			}
+			if results[id].completed && completedBy == "" {
+				completedBy = id
+			}
 		}
 
-		if activeVertices == 0 {
-			fmt.Println("No active vertices. Execution finished.")
+		joins.admit(nextInbox)
+		emitRouted(opts.Observers, wf.ID, step, nextInbox)
+		combineInbox(nextInbox, combiners)
+		inbox = nextInbox
+		step++
+
+		if completedBy != "" {
+			fmt.Printf("Node %s completed the workflow at superstep %d\n", completedBy, step-1)
+			for id, msgs := range inbox {
+				if len(msgs) > 0 {
+					execCtx.SetStatus(id, StatusSkipped)
+				}
+			}
+			for _, id := range joins.waiting() {
+				execCtx.SetStatus(id, StatusSkipped)
+			}
 			break
 		}
 
-		inbox = nextInbox
-		step++
+		if opts.Checkpointer != nil {
+			if err := opts.Checkpointer.SaveCheckpoint(ctx, execCtx.checkpoint(step, inbox, joins, awake)); err != nil {
+				fmt.Printf("Failed to checkpoint workflow %s at superstep %d: %v\n", wf.ID, step, err)
+			}
+		}
 	}
 
 	if step >= maxSteps {
-		return fmt.Errorf("execution exceeded max steps (%d)", maxSteps)
+		if loops := runningLoops(&wf, execCtx); loops != "" {
+			return fmt.Errorf("execution exceeded max supersteps (%d); loops still iterating: %s", maxSteps, loops)
+		}
+		return fmt.Errorf("execution exceeded max supersteps (%d)", maxSteps)
 	}
 
+	outputs := CollectOutputs(wf, execCtx)
+	execCtx.mu.Lock()
+	execCtx.Outputs = outputs
+	execCtx.mu.Unlock()
+
 	return nil
 }
+
+// computeSuperstep runs Compute for every active vertex using at most run.opts.NumWorkers goroutines
+// and blocks until all of them have returned. Failed vertices are retried according to their policy.
+func computeSuperstep(runCtx context.Context, step int, active []string, vertices map[string]Vertex, inbox map[string][]Message, wf *Workflow, execCtx *ExecutionContext, run *runInfo) map[string]vertexResult {
+	numWorkers := run.opts.NumWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	if numWorkers > len(active) {
+		numWorkers = len(active)
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]vertexResult, len(active))
+		jobs    = make(chan string)
+	)
+
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				if err := runCtx.Err(); err != nil {
+					// The run was aborted while this vertex was still queued
+					execCtx.SetStatus(id, abortStatus(err))
+					mu.Lock()
+					results[id] = vertexResult{err: err}
+					mu.Unlock()
+					continue
+				}
+
+				execCtx.SetStatus(id, StatusRunning)
+				startedAt := time.Now()
+				run.opts.Observers.emit(wf.ID, Event{Type: EventNodeStarted, Step: step, NodeID: id})
+				ctx, attempts, lastErr := computeWithRetry(runCtx, step, id, vertices[id], inbox[id], wf, execCtx, run)
+				var err error
+				if ctx == nil && attempts > 1 {
+					err = fmt.Errorf("failed after %d attempts: %w", attempts, lastErr)
+				} else if ctx == nil {
+					err = lastErr
+				}
+
+				record := StepRecord{Step: step, NodeID: id, Attempts: attempts, StartedAt: startedAt, FinishedAt: time.Now()}
+				if err != nil && runCtx.Err() != nil {
+					record.Status = abortStatus(runCtx.Err())
+				} else if err != nil {
+					record.Status = StatusFailed
+				} else {
+					record.Status = StatusSuccess
+				}
+				if lastErr != nil {
+					record.Error = lastErr.Error()
+				}
+				var outbox []Message
+				completed := false
+				if ctx != nil {
+					contributeFromData(ctx)
+					outbox, completed = ctx.Outbox, ctx.completed
+				}
+
+				if err == nil {
+					if attempts > 1 {
+						annotateRetries(execCtx, id, attempts, lastErr)
+					}
+					record.Result, _ = execCtx.GetResult(id)
+				} else if record.Status == StatusFailed {
+					failCtx := &Context{Ctx: runCtx, Step: step, NodeID: id, Workflow: wf, Execution: execCtx, run: run}
+					if handled, ok := handleFailure(failCtx, err); ok {
+						outbox, err = handled, nil
+						record.Result, _ = execCtx.GetResult(id)
+					}
+				}
+				execCtx.SetStatus(id, record.Status)
+				execCtx.RecordStep(record)
+				event := Event{Type: EventNodeSucceeded, Step: step, NodeID: id, Status: record.Status, Duration: record.FinishedAt.Sub(startedAt)}
+				if record.Status != StatusSuccess {
+					event.Type, event.Error = EventNodeFailed, record.Error
+				}
+				run.opts.Observers.emit(wf.ID, event)
+				mu.Lock()
+				r := vertexResult{outbox: outbox, completed: completed, err: err}
+				if ctx != nil {
+					r.stayActive, r.halted, r.contributions = ctx.stayActive, ctx.halted, ctx.contributions
+				}
+				results[id] = r
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, id := range active {
+		jobs <- id
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// computeWithRetry calls Compute until it succeeds, the policy gives up or the run is aborted.
+// The node's result and state are rolled back after every failed attempt. It returns the
+// context of the successful attempt (nil if none succeeded), the number of attempts and the
+// error of the last failed attempt.
+func computeWithRetry(runCtx context.Context, step int, id string, vertex Vertex, messages []Message, wf *Workflow, execCtx *ExecutionContext, run *runInfo) (*Context, int, error) {
+	policy := run.policies[id]
+	snapshot := execCtx.snapshotNode(id)
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		ctx := &Context{
+			Ctx:       runCtx,
+			Step:      step,
+			NodeID:    id,
+			Workflow:  wf,
+			Execution: execCtx,
+			Outbox:    make([]Message, 0),
+			run:       run,
+		}
+		err := vertex.Compute(ctx, messages)
+		if err == nil {
+			return ctx, attempt, lastErr
+		}
+		lastErr = err
+		execCtx.restoreNode(id, snapshot)
+
+		if attempt >= policy.MaxAttempts || runCtx.Err() != nil || !policy.Retries(err) {
+			return nil, attempt, lastErr
+		}
+		delay := policy.Backoff(attempt)
+		fmt.Printf("Node %s failed (attempt %d of %d), retrying in %s: %v\n", id, attempt, policy.MaxAttempts, delay, err)
+		if !sleepContext(runCtx, delay) {
+			return nil, attempt, lastErr
+		}
+	}
+}
+
+// annotateRetries records in a node's result that it only succeeded after retrying
+func annotateRetries(execCtx *ExecutionContext, id string, attempts int, lastErr error) {
+	result, ok := execCtx.GetResult(id)
+	if !ok {
+		return
+	}
+	m, ok := result.(map[string]interface{})
+	if !ok {
+		return
+	}
+	annotated := make(map[string]interface{}, len(m)+2)
+	for k, v := range m {
+		annotated[k] = v
+	}
+	annotated["attempts"] = attempts
+	annotated["last_error"] = lastErr.Error()
+	execCtx.SetResult(id, annotated)
+}
+
+// emitRouted reports the messages delivered to inbox at the barrier of step, by recipient
+func emitRouted(observers Observers, wfID string, step int, inbox map[string][]Message) {
+	if len(observers) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(inbox))
+	for id := range inbox {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		for _, msg := range inbox[id] {
+			observers.emit(wfID, Event{Type: EventMessageRouted, Step: step, From: msg.From, To: msg.To})
+		}
+	}
+}
+
+// abortStatus maps a context error to the node status reported for an aborted run
+func abortStatus(err error) ExecutionStatus {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return StatusTimedOut
+	}
+	return StatusCancelled
+}
+
+// markAborted flags every vertex that still had pending messages when the run was aborted
+func markAborted(execCtx *ExecutionContext, inbox map[string][]Message, err error) {
+	status := abortStatus(err)
+	for id, msgs := range inbox {
+		if len(msgs) > 0 {
+			execCtx.SetStatus(id, status)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// computeFunc is the behaviour of a test vertex
+type computeFunc func(ctx *Context, messages []Message) error
+
+type funcVertex struct {
+	compute computeFunc
+}
+
+func (v *funcVertex) Compute(ctx *Context, messages []Message) error {
+	return v.compute(ctx, messages)
+}
+
+// forward stores the node ID as the node's result and sends it to the node's children
+func forward(ctx *Context, messages []Message) error {
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{"result": ctx.NodeID})
+	return ctx.SendToChildren(map[string]interface{}{"result": ctx.NodeID})
+}
+
+// testRun records how often each vertex computed and what it received
+type testRun struct {
+	mu       sync.Mutex
+	computes map[string]int
+	received map[string][][]Message
+	behavior map[string]computeFunc
+}
+
+func newTestRun(behavior map[string]computeFunc) *testRun {
+	return &testRun{computes: map[string]int{}, received: map[string][][]Message{}, behavior: behavior}
+}
+
+// factory creates vertices that record their calls and then run their behaviour, forward by default
+func (r *testRun) factory() VertexFactory {
+	return func(node Node) (Vertex, error) {
+		id := node.ID
+		return &funcVertex{compute: func(ctx *Context, messages []Message) error {
+			r.mu.Lock()
+			r.computes[id]++
+			r.received[id] = append(r.received[id], messages)
+			compute := r.behavior[id]
+			r.mu.Unlock()
+			if compute == nil {
+				compute = forward
+			}
+			return compute(ctx, messages)
+		}}, nil
+	}
+}
+
+func (r *testRun) count(id string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.computes[id]
+}
+
+func node(id string, nodeType NodeType, data map[string]interface{}) Node {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return Node{ID: id, Type: nodeType, Data: data}
+}
+
+func edge(source, target string) Edge {
+	return Edge{ID: source + "-" + target, Source: source, Target: target}
+}
+
+// memCheckpointer keeps checkpoints after a JSON round trip, as the Redis checkpointer does
+type memCheckpointer struct {
+	mu    sync.Mutex
+	saved []*Checkpoint
+}
+
+func (c *memCheckpointer) SaveCheckpoint(ctx context.Context, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	var decoded Checkpoint
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.saved = append(c.saved, &decoded)
+	return nil
+}
+
+func (c *memCheckpointer) last() *Checkpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.saved) == 0 {
+		return nil
+	}
+	return c.saved[len(c.saved)-1]
+}
+
+func TestExecuteBSPRunsVerticesConcurrently(t *testing.T) {
+	const fanOut = 16
+	const workers = 4
+
+	wf := Workflow{ID: "fan", Nodes: []Node{node("start", NodeTypeStart, nil), node("sink", NodeTypeTask, map[string]interface{}{"wait_for": WaitAll})}}
+	var running, peak int32
+	slow := func(ctx *Context, messages []Message) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return forward(ctx, messages)
+	}
+	behavior := map[string]computeFunc{}
+	for i := 0; i < fanOut; i++ {
+		id := fmt.Sprintf("task%02d", i)
+		wf.Nodes = append(wf.Nodes, node(id, NodeTypeTask, nil))
+		wf.Edges = append(wf.Edges, edge("start", id), edge(id, "sink"))
+		behavior[id] = slow
+	}
+	run := newTestRun(behavior)
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{NumWorkers: workers}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := atomic.LoadInt32(&peak); p < 2 || p > workers {
+		t.Errorf("expected between 2 and %d vertices computing at once, got %d", workers, p)
+	}
+	for i := 0; i < fanOut; i++ {
+		id := fmt.Sprintf("task%02d", i)
+		if execCtx.Status[id] != StatusSuccess {
+			t.Errorf("node %s: expected %s, got %s", id, StatusSuccess, execCtx.Status[id])
+		}
+	}
+	if run.count("sink") != 1 || len(run.received["sink"][0]) != fanOut {
+		t.Errorf("expected the sink to compute once with %d messages, got %v", fanOut, run.received["sink"])
+	}
+}
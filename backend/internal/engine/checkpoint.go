@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"sort"
+)
+
+// Checkpoint is the state of a run at a superstep barrier.
+// Resuming from it continues with superstep Step without re-running completed vertices.
+type Checkpoint struct {
+	WorkflowID string                     `json:"workflow_id"`
+	Step       int                        `json:"step"`
+	Inbox      map[string][]Message       `json:"inbox"`
+	Status     map[string]ExecutionStatus `json:"status"`
+	Results    map[string]interface{}     `json:"results"`
+	History    []StepRecord               `json:"history,omitempty"`
+	NodeState  map[string]interface{}     `json:"node_state,omitempty"`
+	Joins      map[string]*joinState      `json:"joins,omitempty"`
+	Aggregates map[string]interface{}     `json:"aggregates,omitempty"`
+	// Awake are the vertices that stay active in the next superstep without messages
+	Awake []string `json:"awake,omitempty"`
+}
+
+// Checkpointer persists checkpoints of a single run
+type Checkpointer interface {
+	SaveCheckpoint(ctx context.Context, cp *Checkpoint) error
+}
+
+// checkpoint captures the execution context together with the inbox of the next superstep
+// and the messages held back for waiting nodes
+func (e *ExecutionContext) checkpoint(step int, inbox map[string][]Message, joins *joinGate, awake map[string]bool) *Checkpoint {
+	status, results := e.Snapshot()
+	e.mu.RLock()
+	nodeState := make(map[string]interface{}, len(e.NodeState))
+	for k, v := range e.NodeState {
+		nodeState[k] = v
+	}
+	e.mu.RUnlock()
+	return &Checkpoint{
+		WorkflowID: e.WorkflowID,
+		Step:       step,
+		Inbox:      inbox,
+		Status:     status,
+		Results:    results,
+		History:    e.StepHistory(),
+		NodeState:  nodeState,
+		Joins:      joins.snapshot(),
+		Aggregates: e.aggregateSnapshot(),
+		Awake:      sortedKeys(awake),
+	}
+}
+
+// restore replaces the execution context state with the one stored in cp
+func (e *ExecutionContext) restore(cp *Checkpoint) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Status = make(map[string]ExecutionStatus, len(cp.Status))
+	for k, v := range cp.Status {
+		e.Status[k] = v
+	}
+	e.Results = make(map[string]interface{}, len(cp.Results))
+	for k, v := range cp.Results {
+		e.Results[k] = v
+	}
+	e.History = append([]StepRecord(nil), cp.History...)
+	e.NodeState = make(map[string]interface{}, len(cp.NodeState))
+	for k, v := range cp.NodeState {
+		e.NodeState[k] = v
+	}
+	e.Aggregates = make(map[string]interface{}, len(cp.Aggregates))
+	for k, v := range cp.Aggregates {
+		e.Aggregates[k] = v
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
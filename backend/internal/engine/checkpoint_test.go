@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	wf := Workflow{
+		ID:    "resume",
+		Nodes: []Node{node("start", NodeTypeStart, nil), node("a", NodeTypeTask, nil), node("b", NodeTypeTask, nil), node("c", NodeTypeTask, nil)},
+		Edges: []Edge{edge("start", "a"), edge("a", "b"), edge("b", "c")},
+	}
+	crash := errors.New("worker crashed")
+	failing := true
+	run := newTestRun(map[string]computeFunc{
+		"b": func(ctx *Context, messages []Message) error {
+			if failing {
+				return crash
+			}
+			return forward(ctx, messages)
+		},
+	})
+	cp := &memCheckpointer{}
+
+	err := ExecuteBSP(context.Background(), wf, NewExecutionContext(wf.ID), run.factory(), BSPOptions{Checkpointer: cp})
+	if !errors.Is(err, crash) {
+		t.Fatalf("expected the first run to fail with %v, got %v", crash, err)
+	}
+	resume := cp.last()
+	if resume == nil || resume.Step != 2 {
+		t.Fatalf("expected a checkpoint before superstep 2, got %+v", resume)
+	}
+
+	failing = false
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{Resume: resume, NumWorkers: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]int{"start": 1, "a": 1, "b": 2, "c": 1} {
+		if got := run.count(id); got != want {
+			t.Errorf("node %s: expected %d computations, got %d", id, want, got)
+		}
+	}
+	for _, id := range []string{"start", "a", "b", "c"} {
+		if execCtx.Status[id] != StatusSuccess {
+			t.Errorf("node %s: expected %s after resuming, got %s", id, StatusSuccess, execCtx.Status[id])
+		}
+	}
+	if result, _ := execCtx.GetResult("a"); result == nil {
+		t.Error("expected the result of a to be restored from the checkpoint")
+	}
+}
+
+func TestResumeKeepsJoinBuffers(t *testing.T) {
+	// a's message is buffered for the join when c fails; the resumed run must still deliver it
+	wf := Workflow{
+		ID: "resume-join",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("a", NodeTypeTask, nil),
+			node("b", NodeTypeTask, nil),
+			node("c", NodeTypeTask, nil),
+			node("join", NodeTypeJoin, nil),
+		},
+		Edges: []Edge{edge("start", "a"), edge("start", "b"), edge("b", "c"), edge("a", "join"), edge("c", "join")},
+	}
+	failing := true
+	run := newTestRun(map[string]computeFunc{
+		"c": func(ctx *Context, messages []Message) error {
+			if failing {
+				return errors.New("worker crashed")
+			}
+			return forward(ctx, messages)
+		},
+	})
+	cp := &memCheckpointer{}
+
+	if err := ExecuteBSP(context.Background(), wf, NewExecutionContext(wf.ID), run.factory(), BSPOptions{Checkpointer: cp}); err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+
+	failing = false
+	if err := ExecuteBSP(context.Background(), wf, NewExecutionContext(wf.ID), run.factory(), BSPOptions{Resume: cp.last()}); err != nil {
+		t.Fatal(err)
+	}
+	if n := run.count("join"); n != 1 {
+		t.Fatalf("expected the join to compute once, got %d", n)
+	}
+	if got := run.received["join"][0]; len(got) != 2 || got[0].From != "a" || got[1].From != "c" {
+		t.Errorf("expected the buffered message of a together with c's, got %+v", got)
+	}
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Built-in combiners
+const (
+	CombineConcatenate = "concatenate" // merge all messages into one
+	CombineDedupe      = "dedupe"      // drop messages whose content repeats an earlier one
+	CombineLatest      = "latest"      // keep only the last message from each sender
+)
+
+// CombinedSender is the sender of a message merged from several others
+const CombinedSender = "combiner"
+
+// Combiner reduces the messages sent to a vertex in one superstep before the vertex receives
+// them. It is applied in the communication phase, after routing, so it sees the messages in a
+// deterministic order.
+type Combiner interface {
+	Combine(messages []Message) []Message
+}
+
+// CombinerFunc adapts a function to the Combiner interface
+type CombinerFunc func(messages []Message) []Message
+
+func (f CombinerFunc) Combine(messages []Message) []Message {
+	return f(messages)
+}
+
+var (
+	combinersMu sync.RWMutex
+	combiners   = map[string]Combiner{
+		CombineConcatenate: CombinerFunc(concatenateMessages),
+		CombineDedupe:      CombinerFunc(dedupeMessages),
+		CombineLatest:      CombinerFunc(latestMessages),
+	}
+)
+
+// RegisterCombiner adds a custom combiner that nodes and node types can select by name. It is
+// meant to be called during initialization and returns an error if the name is taken.
+func RegisterCombiner(name string, c Combiner) error {
+	combinersMu.Lock()
+	defer combinersMu.Unlock()
+	if _, exists := combiners[name]; exists {
+		return fmt.Errorf("combiner %q is already registered", name)
+	}
+	combiners[name] = c
+	return nil
+}
+
+func lookupCombiner(name string) (Combiner, bool) {
+	combinersMu.RLock()
+	defer combinersMu.RUnlock()
+	c, ok := combiners[name]
+	return c, ok
+}
+
+// concatenateMessages merges messages into one whose "result" joins their results as text and
+// whose "results" and "sources" list the individual results and senders
+func concatenateMessages(messages []Message) []Message {
+	if len(messages) < 2 {
+		return messages
+	}
+	texts := make([]string, 0, len(messages))
+	results := make([]interface{}, 0, len(messages))
+	sources := make([]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		value, ok := msg.Content["result"]
+		if !ok {
+			value = msg.Content
+		}
+		results = append(results, value)
+		sources = append(sources, msg.From)
+		if s, ok := value.(string); ok {
+			texts = append(texts, s)
+		} else {
+			data, _ := json.Marshal(value)
+			texts = append(texts, string(data))
+		}
+	}
+	return []Message{{
+		From: CombinedSender,
+		To:   messages[0].To,
+		Content: map[string]interface{}{
+			"result":  strings.Join(texts, "\n\n"),
+			"results": results,
+			"sources": sources,
+		},
+	}}
+}
+
+func dedupeMessages(messages []Message) []Message {
+	seen := make(map[string]bool, len(messages))
+	kept := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		// encoding/json sorts map keys, so equal contents encode identically
+		data, err := json.Marshal(msg.Content)
+		if err == nil && seen[string(data)] {
+			continue
+		}
+		seen[string(data)] = true
+		kept = append(kept, msg)
+	}
+	return kept
+}
+
+func latestMessages(messages []Message) []Message {
+	last := make(map[string]int, len(messages))
+	for i, msg := range messages {
+		last[msg.From] = i
+	}
+	indexes := make([]int, 0, len(last))
+	for _, i := range last {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	kept := make([]Message, len(indexes))
+	for j, i := range indexes {
+		kept[j] = messages[i]
+	}
+	return kept
+}
+
+// combinerProvider is implemented by vertices whose node type has a default combiner
+type combinerProvider interface {
+	MessageCombiner() Combiner
+}
+
+// combinedVertex attaches the default combiner of a node type to its vertices
+type combinedVertex struct {
+	Vertex
+	combiner Combiner
+}
+
+func (v *combinedVertex) MessageCombiner() Combiner {
+	return v.combiner
+}
+
+// nodeCombiners resolves the combiner of every node: data.combiner if set, otherwise the
+// default of its node type
+func nodeCombiners(wf *Workflow, vertices map[string]Vertex) (map[string]Combiner, error) {
+	result := make(map[string]Combiner)
+	for _, node := range wf.Nodes {
+		if name, ok := node.Data["combiner"].(string); ok && name != "" {
+			c, ok := lookupCombiner(name)
+			if !ok {
+				return nil, fmt.Errorf("node %s: unknown combiner %q", node.ID, name)
+			}
+			result[node.ID] = c
+		} else if p, ok := vertices[node.ID].(combinerProvider); ok {
+			result[node.ID] = p.MessageCombiner()
+		}
+	}
+	return result, nil
+}
+
+// combineInbox applies the combiners to the messages about to be delivered
+func combineInbox(inbox map[string][]Message, combiners map[string]Combiner) {
+	for id, msgs := range inbox {
+		if c, ok := combiners[id]; ok && len(msgs) > 1 {
+			inbox[id] = c.Combine(msgs)
+		}
+	}
+}
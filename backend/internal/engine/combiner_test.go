@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCombinerFoldsMessages(t *testing.T) {
+	wf := Workflow{
+		ID: "combine",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("a", NodeTypeTask, nil),
+			node("b", NodeTypeTask, nil),
+			node("c", NodeTypeTask, nil),
+			node("sink", NodeTypeTask, map[string]interface{}{"combiner": CombineConcatenate}),
+		},
+		Edges: []Edge{edge("start", "a"), edge("start", "b"), edge("start", "c"), edge("a", "sink"), edge("b", "sink"), edge("c", "sink")},
+	}
+	run := newTestRun(nil)
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{NumWorkers: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := run.count("sink"); n != 1 {
+		t.Fatalf("expected the sink to compute once, got %d", n)
+	}
+	msgs := run.received["sink"][0]
+	if len(msgs) != 1 || msgs[0].From != CombinedSender {
+		t.Fatalf("expected one combined message, got %+v", msgs)
+	}
+	sources, _ := msgs[0].Content["sources"].([]interface{})
+	if fmt.Sprint(sources) != "[a b c]" {
+		t.Errorf("expected sources in sender order, got %v", msgs[0].Content["sources"])
+	}
+}
+
+func TestBuiltinCombiners(t *testing.T) {
+	msgs := []Message{
+		{From: "a", To: "x", Content: map[string]interface{}{"result": "1"}},
+		{From: "b", To: "x", Content: map[string]interface{}{"result": "1"}},
+		{From: "a", To: "x", Content: map[string]interface{}{"result": "2"}},
+	}
+
+	if got := dedupeMessages(msgs); len(got) != 2 || got[0].From != "a" || got[1].Content["result"] != "2" {
+		t.Errorf("dedupe: expected the first copy of each content, got %+v", got)
+	}
+	if got := latestMessages(msgs); len(got) != 2 || got[0].From != "b" || got[1].Content["result"] != "2" {
+		t.Errorf("latest: expected the last message of each sender in order, got %+v", got)
+	}
+	got := concatenateMessages(msgs)
+	if len(got) != 1 || got[0].To != "x" || got[0].Content["result"] != "1\n\n1\n\n2" {
+		t.Errorf("concatenate: unexpected result %+v", got)
+	}
+}
+
+func TestUnknownCombinerFailsTheRun(t *testing.T) {
+	wf := Workflow{ID: "unknown", Nodes: []Node{node("start", NodeTypeStart, map[string]interface{}{"combiner": "nope"})}}
+	err := ExecuteBSP(context.Background(), wf, NewExecutionContext(wf.ID), newTestRun(nil).factory(), BSPOptions{})
+	if err == nil {
+		t.Fatal("expected an error for an unknown combiner")
+	}
+	if err := RegisterCombiner(CombineLatest, CombinerFunc(latestMessages)); err == nil {
+		t.Error("expected registering a taken name to fail")
+	}
+}
@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Condition operators supported on edges
+const (
+	OpEquals      = "equals"
+	OpNotEquals   = "not_equals"
+	OpContains    = "contains"
+	OpNotContains = "not_contains"
+	OpMatches     = "matches"
+	OpExists      = "exists"
+	OpGreater     = "gt"
+	OpGreaterEq   = "gte"
+	OpLess        = "lt"
+	OpLessEq      = "lte"
+	// OpElse marks a default edge taken only when no other conditional edge of the source matched
+	OpElse = "else"
+)
+
+// Condition guards an edge: a message only flows along it if the source node's output matches
+type Condition struct {
+	// Field is a dot-separated path into the output, e.g. "result" or "result.score". Defaults to "result".
+	Field      string      `json:"field,omitempty"`
+	Operator   string      `json:"operator"`
+	Value      interface{} `json:"value,omitempty"`
+	IgnoreCase bool        `json:"ignore_case,omitempty"`
+}
+
+// DecodeCondition converts a condition stored in node data (a JSON object) into a Condition.
+// It returns nil if v is nil.
+func DecodeCondition(v interface{}) (*Condition, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c Condition
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid condition: %w", err)
+	}
+	if c.Operator == "" {
+		return nil, fmt.Errorf("invalid condition: missing operator")
+	}
+	return &c, nil
+}
+
+// IsElse reports whether the condition is a default "else" branch
+func (c *Condition) IsElse() bool {
+	return c != nil && c.Operator == OpElse
+}
+
+// Evaluate checks the condition against a node output
+func (c *Condition) Evaluate(output map[string]interface{}) (bool, error) {
+	field := c.Field
+	if field == "" {
+		field = "result"
+	}
+	actual, found := lookupPath(output, field)
+
+	switch c.Operator {
+	case OpExists:
+		return found && actual != nil, nil
+	case OpElse:
+		return false, nil
+	}
+	if !found {
+		return false, nil
+	}
+
+	switch c.Operator {
+	case OpEquals, OpNotEquals:
+		equal := valuesEqual(actual, c.Value, c.IgnoreCase)
+		return equal == (c.Operator == OpEquals), nil
+	case OpContains, OpNotContains:
+		haystack, needle := stringify(actual), stringify(c.Value)
+		if c.IgnoreCase {
+			haystack, needle = strings.ToLower(haystack), strings.ToLower(needle)
+		}
+		contains := strings.Contains(haystack, needle)
+		return contains == (c.Operator == OpContains), nil
+	case OpMatches:
+		pattern := stringify(c.Value)
+		if c.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return re.MatchString(stringify(actual)), nil
+	case OpGreater, OpGreaterEq, OpLess, OpLessEq:
+		a, okA := toFloat(actual)
+		b, okB := toFloat(c.Value)
+		if !okA || !okB {
+			return false, nil
+		}
+		switch c.Operator {
+		case OpGreater:
+			return a > b, nil
+		case OpGreaterEq:
+			return a >= b, nil
+		case OpLess:
+			return a < b, nil
+		default:
+			return a <= b, nil
+		}
+	default:
+		return false, fmt.Errorf("unknown condition operator %q", c.Operator)
+	}
+}
+
+// lookupPath resolves a dot-separated path through nested maps
+func lookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func valuesEqual(a, b interface{}, ignoreCase bool) bool {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return fa == fb
+		}
+	}
+	sa, sb := strings.TrimSpace(stringify(a)), strings.TrimSpace(stringify(b))
+	if ignoreCase {
+		return strings.EqualFold(sa, sb)
+	}
+	return sa == sb
+}
+
+func stringify(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// MatchingEdges returns the outgoing edges of source that a message with the given content
+// should follow. Unconditional edges always match; "else" edges match only when no other
+// conditional edge did. If firstOnly is set, only the first matching conditional edge is taken.
+// Error edges are never matched.
+func MatchingEdges(wf *Workflow, source string, content map[string]interface{}, firstOnly bool) ([]Edge, error) {
+	var matched, elseEdges []Edge
+	conditionalMatched := false
+
+	for _, edge := range wf.Edges {
+		if edge.Source != source || edge.SourceHandle == ErrorHandle {
+			continue
+		}
+		switch {
+		case edge.Condition == nil:
+			matched = append(matched, edge)
+		case edge.Condition.IsElse():
+			elseEdges = append(elseEdges, edge)
+		case firstOnly && conditionalMatched:
+			continue
+		default:
+			ok, err := edge.Condition.Evaluate(content)
+			if err != nil {
+				return nil, fmt.Errorf("edge %s: %w", edge.ID, err)
+			}
+			if ok {
+				matched = append(matched, edge)
+				conditionalMatched = true
+			}
+		}
+	}
+
+	if !conditionalMatched {
+		matched = append(matched, elseEdges...)
+	}
+	return matched, nil
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	output := map[string]interface{}{
+		"result": "Approved by the Reviewer",
+		"score":  0.8,
+		"nested": map[string]interface{}{"count": "3"},
+	}
+	tests := []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Operator: OpEquals, Value: "Approved by the Reviewer"}, true},
+		{Condition{Operator: OpEquals, Value: "approved by the reviewer"}, false},
+		{Condition{Operator: OpEquals, Value: "approved by the reviewer", IgnoreCase: true}, true},
+		{Condition{Operator: OpNotEquals, Value: "Rejected"}, true},
+		{Condition{Operator: OpContains, Value: "Reviewer"}, true},
+		{Condition{Operator: OpNotContains, Value: "Reviewer"}, false},
+		{Condition{Operator: OpMatches, Value: `^approved\b`, IgnoreCase: true}, true},
+		{Condition{Field: "score", Operator: OpGreater, Value: 0.5}, true},
+		{Condition{Field: "score", Operator: OpLessEq, Value: "0.8"}, true},
+		{Condition{Field: "nested.count", Operator: OpGreaterEq, Value: 3}, true},
+		{Condition{Field: "nested.count", Operator: OpEquals, Value: 3.0}, true},
+		{Condition{Field: "score", Operator: OpLess, Value: "not a number"}, false},
+		{Condition{Field: "nested.count", Operator: OpExists}, true},
+		{Condition{Field: "missing", Operator: OpExists}, false},
+		{Condition{Field: "missing", Operator: OpNotEquals, Value: "x"}, false},
+		{Condition{Operator: OpElse}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.cond.Evaluate(output)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.cond, err)
+		} else if got != tt.want {
+			t.Errorf("%+v: expected %v, got %v", tt.cond, tt.want, got)
+		}
+	}
+
+	if _, err := (&Condition{Operator: OpMatches, Value: "("}).Evaluate(output); err == nil {
+		t.Error("expected an invalid pattern to fail")
+	}
+	if _, err := (&Condition{Operator: "between"}).Evaluate(output); err == nil {
+		t.Error("expected an unknown operator to fail")
+	}
+}
+
+func TestMatchingEdges(t *testing.T) {
+	conditional := func(target string, cond Condition) Edge {
+		e := edge("src", target)
+		e.Condition = &cond
+		return e
+	}
+	failure := edge("src", "on-error")
+	failure.SourceHandle = ErrorHandle
+	wf := &Workflow{Edges: []Edge{
+		edge("src", "always"),
+		conditional("high", Condition{Field: "score", Operator: OpGreater, Value: 5}),
+		conditional("positive", Condition{Field: "score", Operator: OpGreater, Value: 0}),
+		conditional("fallback", Condition{Operator: OpElse}),
+		failure,
+	}}
+	targets := func(content map[string]interface{}, firstOnly bool) []string {
+		edges, err := MatchingEdges(wf, "src", content, firstOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(edges))
+		for i, e := range edges {
+			ids[i] = e.Target
+		}
+		return ids
+	}
+
+	tests := []struct {
+		score     float64
+		firstOnly bool
+		want      []string
+	}{
+		{10, false, []string{"always", "high", "positive"}},
+		{10, true, []string{"always", "high"}},
+		{1, false, []string{"always", "positive"}},
+		{-1, false, []string{"always", "fallback"}},
+	}
+	for _, tt := range tests {
+		if got := targets(map[string]interface{}{"score": tt.score}, tt.firstOnly); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("score %v (firstOnly %v): expected %v, got %v", tt.score, tt.firstOnly, tt.want, got)
+		}
+	}
+}
@@ -12,8 +12,12 @@ func NewEngine() *Engine {
 	return &Engine{}
 }
 
-func (e *Engine) Run(wf Workflow) {
+// Run executes the workflow node by node in dependency order, reporting progress to the observers
+func (e *Engine) Run(wf Workflow, observers ...Observer) {
 	fmt.Printf("Starting execution of workflow: %s\n", wf.ID)
+	obs := Observers(observers)
+	startedAt := time.Now()
+	obs.emit(wf.ID, Event{Type: EventRunStarted})
 
 	// Build adjacency list and in-degree map
 	adj := make(map[string][]string)
@@ -38,7 +42,7 @@ func (e *Engine) Run(wf Workflow) {
 	var activeNodes int
 	for id, deg := range inDegree {
 		if deg == 0 {
-			go e.executeNode(nodes[id], done)
+			go e.executeNode(wf.ID, nodes[id], done, obs)
 			activeNodes++
 		}
 	}
@@ -50,23 +54,28 @@ func (e *Engine) Run(wf Workflow) {
 
 		// Check children
 		for _, childID := range adj[completedNodeID] {
+			obs.emit(wf.ID, Event{Type: EventMessageRouted, From: completedNodeID, To: childID})
 			inDegree[childID]--
 			if inDegree[childID] == 0 {
-				go e.executeNode(nodes[childID], done)
+				go e.executeNode(wf.ID, nodes[childID], done, obs)
 				activeNodes++
 			}
 		}
 	}
 
 	fmt.Printf("Workflow %s execution completed.\n", wf.ID)
+	obs.emit(wf.ID, Event{Type: EventRunFinished, Duration: time.Since(startedAt)})
 }
 
-func (e *Engine) executeNode(node Node, done chan<- string) {
+func (e *Engine) executeNode(wfID string, node Node, done chan<- string, obs Observers) {
 	fmt.Printf("Executing node: %v (%s)\n", node.Data["label"], node.Type)
+	startedAt := time.Now()
+	obs.emit(wfID, Event{Type: EventNodeStarted, NodeID: node.ID})
 
 	// Simulate work
 	time.Sleep(1 * time.Second)
 
 	fmt.Printf("Node finished: %v\n", node.Data["label"])
+	obs.emit(wfID, Event{Type: EventNodeSucceeded, NodeID: node.ID, Status: StatusSuccess, Duration: time.Since(startedAt)})
 	done <- node.ID
 }
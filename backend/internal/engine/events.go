@@ -0,0 +1,77 @@
+package engine
+
+import "time"
+
+// EventType identifies what an Event reports
+type EventType string
+
+const (
+	EventRunStarted       EventType = "run_started"
+	EventSuperstepStarted EventType = "superstep_started"
+	EventNodeStarted      EventType = "node_started"
+	EventNodeSucceeded    EventType = "node_succeeded"
+	EventNodeFailed       EventType = "node_failed"
+	EventMessageRouted    EventType = "message_routed"
+	EventRunFinished      EventType = "run_finished"
+)
+
+// Event describes something that happened during a run. Fields that don't apply to the
+// event type are left empty.
+type Event struct {
+	Type       EventType `json:"type"`
+	WorkflowID string    `json:"workflow_id"`
+	Step       int       `json:"step"`
+	NodeID     string    `json:"node_id,omitempty"`
+	// From and To are the sender and recipient of a routed message. A message is reported
+	// once it is delivered: messages held back by a join at the barrier that releases them,
+	// and messages folded by a combiner one by one with their original sender.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Status is the final status of a finished node
+	Status ExecutionStatus `json:"status,omitempty"`
+	// Duration is how long a node computed, including retries, or how long the run took
+	Duration time.Duration `json:"duration,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Time     time.Time     `json:"time"`
+}
+
+// Observer receives the events of a run. Node events are emitted from the worker goroutines of
+// a superstep, so OnEvent must be safe for concurrent use, and it should return quickly since
+// the run waits for it.
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface
+type ObserverFunc func(event Event)
+
+func (f ObserverFunc) OnEvent(event Event) {
+	f(event)
+}
+
+// Observers fans every event out to each of its subscribers in order
+type Observers []Observer
+
+func (o Observers) OnEvent(event Event) {
+	for _, observer := range o {
+		observer.OnEvent(event)
+	}
+}
+
+// emit stamps the event with the workflow ID and time and delivers it to the observers
+func (o Observers) emit(wfID string, event Event) {
+	if len(o) == 0 {
+		return
+	}
+	event.WorkflowID = wfID
+	event.Time = time.Now()
+	o.OnEvent(event)
+}
+
+// errorString returns the message of err, or "" if it is nil
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
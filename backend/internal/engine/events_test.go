@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// eventLog records events as short strings, e.g. "node_started wf:a@1"
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) OnEvent(e Event) {
+	s := fmt.Sprintf("%s %s", e.Type, e.WorkflowID)
+	switch e.Type {
+	case EventSuperstepStarted:
+		s += fmt.Sprintf("@%d", e.Step)
+	case EventNodeStarted, EventNodeSucceeded, EventNodeFailed:
+		s += fmt.Sprintf(":%s@%d", e.NodeID, e.Step)
+	case EventMessageRouted:
+		s += fmt.Sprintf(":%s->%s@%d", e.From, e.To, e.Step)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, s)
+}
+
+func (l *eventLog) filter(eventType EventType) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []string
+	for _, s := range l.events {
+		if strings.HasPrefix(s, string(eventType)+" ") {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func TestObserversReceiveEventsInOrder(t *testing.T) {
+	wf := Workflow{
+		ID:    "wf",
+		Nodes: []Node{node("start", NodeTypeStart, nil), node("a", NodeTypeTask, nil), node("b", NodeTypeTask, nil)},
+		Edges: []Edge{edge("start", "a"), edge("a", "b")},
+	}
+	first, second := &eventLog{}, &eventLog{}
+
+	execCtx := NewExecutionContext(wf.ID)
+	opts := BSPOptions{Observers: Observers{first, second}}
+	if err := ExecuteBSP(context.Background(), wf, execCtx, newTestRun(nil).factory(), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"run_started wf",
+		"superstep_started wf@0",
+		"node_started wf:start@0",
+		"node_succeeded wf:start@0",
+		"message_routed wf:start->a@0",
+		"superstep_started wf@1",
+		"node_started wf:a@1",
+		"node_succeeded wf:a@1",
+		"message_routed wf:a->b@1",
+		"superstep_started wf@2",
+		"node_started wf:b@2",
+		"node_succeeded wf:b@2",
+		"run_finished wf",
+	}
+	if !reflect.DeepEqual(first.events, want) {
+		t.Errorf("expected events\n%v\ngot\n%v", want, first.events)
+	}
+	if !reflect.DeepEqual(second.events, first.events) {
+		t.Errorf("expected every observer to receive the same events, got\n%v\nand\n%v", first.events, second.events)
+	}
+}
+
+func TestMessageRoutedReportsJoinReleases(t *testing.T) {
+	// j waits for a (done at step 1) and c (done at step 2)
+	wf := Workflow{
+		ID: "wf",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("a", NodeTypeTask, nil),
+			node("b", NodeTypeTask, nil),
+			node("c", NodeTypeTask, nil),
+			node("j", NodeTypeTask, map[string]interface{}{"wait_for": WaitAll}),
+		},
+		Edges: []Edge{edge("start", "a"), edge("start", "b"), edge("b", "c"), edge("a", "j"), edge("c", "j")},
+	}
+	log := &eventLog{}
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, newTestRun(nil).factory(), BSPOptions{Observers: Observers{log}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var toJoin []string
+	for _, s := range log.filter(EventMessageRouted) {
+		if strings.Contains(s, "->j@") {
+			toJoin = append(toJoin, s)
+		}
+	}
+	want := []string{"message_routed wf:a->j@2", "message_routed wf:c->j@2"}
+	if !reflect.DeepEqual(toJoin, want) {
+		t.Errorf("expected the join's messages to be reported when released, got %v", toJoin)
+	}
+}
+
+func TestObserversReceiveSubWorkflowEvents(t *testing.T) {
+	inner := Workflow{ID: "inner", Nodes: []Node{node("inner-start", NodeTypeStart, nil)}}
+	wf := Workflow{
+		ID:    "outer",
+		Nodes: []Node{node("start", NodeTypeStart, nil), node("sub", NodeTypeSubWorkflow, nil)},
+		Edges: []Edge{edge("start", "sub")},
+	}
+	run := newTestRun(map[string]computeFunc{
+		"sub": func(ctx *Context, messages []Message) error {
+			if _, err := ctx.RunSubWorkflow(inner, nil); err != nil {
+				return err
+			}
+			return forward(ctx, messages)
+		},
+	})
+	log := &eventLog{}
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{Observers: Observers{log}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"node_started outer:sub@1",
+		"run_started inner",
+		"superstep_started inner@0",
+		"node_started inner:inner-start@0",
+		"node_succeeded inner:inner-start@0",
+		"run_finished inner",
+		"node_succeeded outer:sub@1",
+	}
+	got := log.events
+	for len(got) > 0 && got[0] != want[0] {
+		got = got[1:]
+	}
+	if len(got) < len(want) || !reflect.DeepEqual(got[:len(want)], want) {
+		t.Errorf("expected the sub-workflow's events while its node runs\n%v\ngot\n%v", want, log.events)
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// onErrorMode returns the failure handling mode of a node
+func onErrorMode(node *Node) string {
+	if node == nil {
+		return OnErrorFail
+	}
+	if mode, ok := node.Data["on_error"].(string); ok && mode != "" {
+		return mode
+	}
+	return OnErrorFail
+}
+
+// handleFailure applies the node's on_error mode to a failed Compute. It returns the
+// messages to route in place of the node's output, or false if the failure aborts the run.
+func handleFailure(ctx *Context, err error) ([]Message, bool) {
+	mode := onErrorMode(ctx.Node())
+	if mode == OnErrorFail {
+		return nil, false
+	}
+
+	content := map[string]interface{}{
+		"error":       err.Error(),
+		"error_class": ErrorClass(err),
+		"failed_node": ctx.NodeID,
+	}
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{"error": err.Error(), "failed": true})
+
+	switch mode {
+	case OnErrorContinue:
+		if sendErr := ctx.SendToChildren(content); sendErr != nil {
+			fmt.Printf("Node %s: failed to route error message: %v\n", ctx.NodeID, sendErr)
+		}
+	case OnErrorRoute:
+		for _, edge := range ctx.Workflow.Edges {
+			if edge.Source == ctx.NodeID && edge.SourceHandle == ErrorHandle {
+				ctx.SendMessage(edge.Target, content)
+			}
+		}
+	}
+	fmt.Printf("Node %s failed, continuing (on_error=%s): %v\n", ctx.NodeID, mode, err)
+	return ctx.Outbox, true
+}
+
+// validateErrorHandling checks a node's on_error mode against its error edges
+func validateErrorHandling(node *Node, wf Workflow) []string {
+	mode := onErrorMode(node)
+	switch mode {
+	case OnErrorFail, OnErrorContinue, OnErrorRoute:
+	default:
+		return []string{fmt.Sprintf("on_error: unknown mode %q, expected %q, %q or %q", mode, OnErrorFail, OnErrorContinue, OnErrorRoute)}
+	}
+
+	errorEdges := 0
+	for _, edge := range wf.Edges {
+		if edge.Source == node.ID && edge.SourceHandle == ErrorHandle {
+			errorEdges++
+		}
+	}
+	if mode == OnErrorRoute && errorEdges == 0 {
+		return []string{fmt.Sprintf("on_error is %q but the node has no error edge", OnErrorRoute)}
+	}
+	if mode != OnErrorRoute && errorEdges > 0 {
+		return []string{fmt.Sprintf("the node has error edges, which are only taken with on_error %q", OnErrorRoute)}
+	}
+	return nil
+}
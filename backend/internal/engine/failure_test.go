@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// onErrorRun runs start -> flaky -> next, with an error edge from flaky to handler, where
+// flaky always fails
+func onErrorRun(t *testing.T, mode string) (*ExecutionContext, *testRun, error) {
+	t.Helper()
+	failure := edge("flaky", "handler")
+	failure.SourceHandle = ErrorHandle
+	wf := Workflow{
+		ID: "wf",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("flaky", NodeTypeTask, map[string]interface{}{"on_error": mode}),
+			node("next", NodeTypeTask, nil),
+			node("handler", NodeTypeTask, nil),
+		},
+		Edges: []Edge{edge("start", "flaky"), edge("flaky", "next"), failure},
+	}
+	run := newTestRun(map[string]computeFunc{
+		"flaky": func(ctx *Context, messages []Message) error { return errors.New("model unavailable") },
+	})
+	execCtx := NewExecutionContext(wf.ID)
+	err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{})
+	return execCtx, run, err
+}
+
+func TestOnErrorFailAbortsTheRun(t *testing.T) {
+	execCtx, run, err := onErrorRun(t, OnErrorFail)
+	if err == nil {
+		t.Fatal("expected the failure to abort the run")
+	}
+	if execCtx.Status["flaky"] != StatusFailed {
+		t.Errorf("expected flaky to be %s, got %s", StatusFailed, execCtx.Status["flaky"])
+	}
+	if run.count("next") != 0 || run.count("handler") != 0 {
+		t.Errorf("expected no node to run after the failure, got next=%d handler=%d", run.count("next"), run.count("handler"))
+	}
+}
+
+func TestOnErrorContinueSendsTheErrorToChildren(t *testing.T) {
+	execCtx, run, err := onErrorRun(t, OnErrorContinue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if execCtx.Status["flaky"] != StatusFailed {
+		t.Errorf("expected flaky to be %s, got %s", StatusFailed, execCtx.Status["flaky"])
+	}
+	if run.count("next") != 1 || run.count("handler") != 0 {
+		t.Fatalf("expected only next to run, got next=%d handler=%d", run.count("next"), run.count("handler"))
+	}
+	content := run.received["next"][0][0].Content
+	if content["error"] != "model unavailable" || content["failed_node"] != "flaky" || content["error_class"] != ErrorClassOther {
+		t.Errorf("expected next to receive the error, got %v", content)
+	}
+	result, _ := execCtx.GetResult("flaky")
+	if m := result.(map[string]interface{}); m["failed"] != true {
+		t.Errorf("expected flaky's result to record the failure, got %v", m)
+	}
+}
+
+func TestOnErrorRouteTakesErrorEdges(t *testing.T) {
+	_, run, err := onErrorRun(t, OnErrorRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.count("handler") != 1 || run.count("next") != 0 {
+		t.Fatalf("expected only the error edge to be taken, got next=%d handler=%d", run.count("next"), run.count("handler"))
+	}
+	if content := run.received["handler"][0][0].Content; content["failed_node"] != "flaky" {
+		t.Errorf("expected the handler to receive the error, got %v", content)
+	}
+}
+
+func TestValidateErrorHandling(t *testing.T) {
+	failure := Edge{ID: "failure", Source: "a", Target: "b", SourceHandle: ErrorHandle}
+	tests := []struct {
+		mode  string
+		edges []Edge
+		valid bool
+	}{
+		{"", nil, true},
+		{OnErrorContinue, nil, true},
+		{OnErrorRoute, []Edge{failure}, true},
+		{OnErrorRoute, nil, false},
+		{OnErrorContinue, []Edge{failure}, false},
+		{"retry", nil, false},
+	}
+	for _, tt := range tests {
+		n := node("a", NodeTypeTask, map[string]interface{}{"on_error": tt.mode})
+		msgs := validateErrorHandling(&n, Workflow{Edges: tt.edges})
+		if valid := len(msgs) == 0; valid != tt.valid {
+			t.Errorf("on_error %q with %d error edges: expected valid=%v, got %v", tt.mode, len(tt.edges), tt.valid, msgs)
+		}
+	}
+}
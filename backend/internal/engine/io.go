@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// InputParam declares a workflow input parameter
+type InputParam struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"` // string, number, integer, boolean, object, array or any
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// OutputParam declares a named workflow output
+type OutputParam struct {
+	Name string `json:"name"`
+	// Source is an expression such as "nodes.summarize.result" or "nodes.end.outputs.summary | trim"
+	Source      string `json:"source"`
+	Description string `json:"description,omitempty"`
+}
+
+var (
+	paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	inputTypes       = map[string]bool{"string": true, "number": true, "integer": true, "boolean": true, "object": true, "array": true, "any": true}
+)
+
+// inputSchema is the schema values of an input must match
+func inputSchema(param InputParam) *Schema {
+	if param.Type == "any" {
+		return &Schema{}
+	}
+	return &Schema{Type: param.Type}
+}
+
+// ResolveInputs checks the provided values against the workflow's declared inputs and
+// fills in defaults. Values for undeclared inputs are rejected.
+func ResolveInputs(wf Workflow, provided map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(wf.Inputs))
+	declared := make(map[string]bool, len(wf.Inputs))
+	var problems []string
+
+	for _, param := range wf.Inputs {
+		declared[param.Name] = true
+		value, ok := provided[param.Name]
+		if !ok || value == nil {
+			if param.Default != nil {
+				resolved[param.Name] = param.Default
+			} else if param.Required {
+				problems = append(problems, fmt.Sprintf("missing required input %q", param.Name))
+			}
+			continue
+		}
+		if msgs := inputSchema(param).Validate(value); len(msgs) > 0 {
+			problems = append(problems, fmt.Sprintf("input %q: %s", param.Name, strings.Join(msgs, ", ")))
+			continue
+		}
+		resolved[param.Name] = value
+	}
+
+	var unknown []string
+	for name := range provided {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("unknown input %q", name))
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid inputs: %s", strings.Join(problems, "; "))
+	}
+	return resolved, nil
+}
+
+// CollectOutputs evaluates the workflow's declared outputs against the results of a finished run.
+// Outputs whose source produced nothing (e.g. an untaken branch) are reported as nil.
+func CollectOutputs(wf Workflow, execCtx *ExecutionContext) map[string]interface{} {
+	scope := TemplateScope{
+		Node: func(nodeID string) (map[string]interface{}, bool) {
+			result, ok := execCtx.GetResult(nodeID)
+			if !ok {
+				return nil, false
+			}
+			m, ok := result.(map[string]interface{})
+			return m, ok
+		},
+		Inputs:     execCtx.Inputs,
+		Aggregates: execCtx.aggregateSnapshot(),
+	}
+
+	// Without declarations, the outputs are whatever the END nodes collected
+	if len(wf.Outputs) == 0 {
+		outputs := make(map[string]interface{})
+		for _, node := range wf.Nodes {
+			if node.Type != NodeTypeEnd {
+				continue
+			}
+			if end, ok := scope.Node(node.ID); ok {
+				if collected, ok := end["outputs"].(map[string]interface{}); ok {
+					for k, v := range collected {
+						outputs[k] = v
+					}
+				}
+			}
+		}
+		return outputs
+	}
+
+	outputs := make(map[string]interface{}, len(wf.Outputs))
+	for _, out := range wf.Outputs {
+		value, err := EvalExpression(out.Source, scope)
+		if err != nil {
+			fmt.Printf("Output %s not available: %v\n", out.Name, err)
+			value = nil
+		}
+		outputs[out.Name] = value
+	}
+	return outputs
+}
+
+// validateIO checks input and output declarations and returns one message per problem
+func validateIO(wf Workflow, nodes map[string]*Node) []string {
+	var msgs []string
+
+	seen := map[string]bool{}
+	for i, param := range wf.Inputs {
+		switch {
+		case !paramNamePattern.MatchString(param.Name):
+			msgs = append(msgs, fmt.Sprintf("input #%d has invalid name %q", i, param.Name))
+		case seen[param.Name]:
+			msgs = append(msgs, fmt.Sprintf("duplicate input %q", param.Name))
+		}
+		seen[param.Name] = true
+		if !inputTypes[param.Type] {
+			msgs = append(msgs, fmt.Sprintf("input %q has unknown type %q", param.Name, param.Type))
+		} else if param.Default != nil {
+			if errs := inputSchema(param).Validate(param.Default); len(errs) > 0 {
+				msgs = append(msgs, fmt.Sprintf("input %q: default %s", param.Name, strings.Join(errs, ", ")))
+			}
+		}
+	}
+
+	seen = map[string]bool{}
+	for i, out := range wf.Outputs {
+		switch {
+		case !paramNamePattern.MatchString(out.Name):
+			msgs = append(msgs, fmt.Sprintf("output #%d has invalid name %q", i, out.Name))
+		case seen[out.Name]:
+			msgs = append(msgs, fmt.Sprintf("duplicate output %q", out.Name))
+		}
+		seen[out.Name] = true
+
+		part, err := parseExpression(out.Source)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("output %q: %v", out.Name, err))
+			continue
+		}
+		if msg := checkRef(wf, nodes, *part.ref); msg != "" {
+			msgs = append(msgs, fmt.Sprintf("output %q: %s", out.Name, msg))
+		}
+	}
+	return msgs
+}
+
+// checkRef verifies that a template reference names an existing node or a declared input
+func checkRef(wf Workflow, nodes map[string]*Node, ref TemplateRef) string {
+	switch ref.Namespace {
+	case TemplateNodes:
+		if _, ok := nodes[ref.Key]; !ok {
+			return fmt.Sprintf("{{%s}} references unknown node %q", ref, ref.Key)
+		}
+	case TemplateInputs:
+		for _, param := range wf.Inputs {
+			if param.Name == ref.Key {
+				return ""
+			}
+		}
+		return fmt.Sprintf("{{%s}} references undeclared input %q", ref, ref.Key)
+	case TemplateAggregates:
+		for _, spec := range wf.Aggregators {
+			if spec.Name == ref.Key {
+				return ""
+			}
+		}
+		return fmt.Sprintf("{{%s}} references undeclared aggregator %q", ref, ref.Key)
+	}
+	return ""
+}
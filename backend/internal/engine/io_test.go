@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveInputs(t *testing.T) {
+	wf := Workflow{Inputs: []InputParam{
+		{Name: "topic", Type: "string", Required: true},
+		{Name: "count", Type: "integer", Default: 3.0},
+		{Name: "tags", Type: "array"},
+		{Name: "extra", Type: "any"},
+	}}
+
+	got, err := ResolveInputs(wf, map[string]interface{}{"topic": "Go", "extra": map[string]interface{}{"a": 1.0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"topic": "Go", "count": 3.0, "extra": map[string]interface{}{"a": 1.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	_, err = ResolveInputs(wf, map[string]interface{}{"count": "three", "tags": "go", "color": "red"})
+	if err == nil {
+		t.Fatal("expected invalid inputs to be rejected")
+	}
+	for _, problem := range []string{`missing required input "topic"`, `input "count"`, `input "tags"`, `unknown input "color"`} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected the error to report %s, got %v", problem, err)
+		}
+	}
+}
+
+func TestDeclaredOutputsAreCollected(t *testing.T) {
+	wf := Workflow{
+		ID:     "wf",
+		Inputs: []InputParam{{Name: "topic", Type: "string"}},
+		Outputs: []OutputParam{
+			{Name: "summary", Source: "nodes.summarize.result | upper"},
+			{Name: "topic", Source: "inputs.topic"},
+			{Name: "skipped", Source: "nodes.untaken.result"},
+		},
+		Nodes: []Node{node("start", NodeTypeStart, nil), node("summarize", NodeTypeTask, nil), node("untaken", NodeTypeTask, nil)},
+		Edges: []Edge{
+			edge("start", "summarize"),
+			{ID: "never", Source: "summarize", Target: "untaken", Condition: &Condition{Operator: OpEquals, Value: "nope"}},
+		},
+	}
+
+	execCtx := NewExecutionContext(wf.ID)
+	execCtx.Inputs = map[string]interface{}{"topic": "Go"}
+	if err := ExecuteBSP(context.Background(), wf, execCtx, newTestRun(nil).factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{"summary": "SUMMARIZE", "topic": "Go", "skipped": nil}
+	if !reflect.DeepEqual(execCtx.Outputs, want) {
+		t.Errorf("expected outputs %v, got %v", want, execCtx.Outputs)
+	}
+}
+
+func TestOutputsDefaultToEndNodes(t *testing.T) {
+	execCtx := NewExecutionContext("wf")
+	execCtx.SetResult("end", map[string]interface{}{"outputs": map[string]interface{}{"writer": "draft"}})
+	wf := Workflow{ID: "wf", Nodes: []Node{node("end", NodeTypeEnd, nil)}}
+
+	if got := CollectOutputs(wf, execCtx); !reflect.DeepEqual(got, map[string]interface{}{"writer": "draft"}) {
+		t.Errorf("expected the END node's outputs, got %v", got)
+	}
+}
+
+func TestValidateReportsIODeclarations(t *testing.T) {
+	wf := Workflow{
+		ID: "wf",
+		Inputs: []InputParam{
+			{Name: "topic", Type: "string"},
+			{Name: "topic", Type: "string"},
+			{Name: "1st", Type: "string"},
+			{Name: "size", Type: "float"},
+			{Name: "count", Type: "integer", Default: "many"},
+		},
+		Outputs: []OutputParam{
+			{Name: "summary", Source: "nodes.missing.result"},
+			{Name: "lang", Source: "inputs.language"},
+		},
+		Nodes: []Node{node("start", NodeTypeStart, nil)},
+	}
+
+	var msgs []string
+	for _, issue := range issues(t, wf) {
+		if issue.Code == IssueInputOutput {
+			msgs = append(msgs, issue.Message)
+		}
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{`duplicate input "topic"`, `invalid name "1st"`, `unknown type "float"`, `input "count": default`, `unknown node "missing"`, `undeclared input "language"`} {
+		if !strings.Contains(all, want) {
+			t.Errorf("expected an issue containing %s, got\n%s", want, all)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Wait-for policies a node selects with data.wait_for. Without one, a node computes in every
+// superstep it receives messages; JOIN nodes default to WaitAll.
+const (
+	WaitAll    = "all"    // wait until every parent has delivered
+	WaitAny    = "any"    // compute on the first delivery and drop the rest of that round
+	WaitQuorum = "quorum" // wait until data.quorum parents have delivered
+)
+
+// joinState is the progress of a waiting node. It is part of checkpoints.
+type joinState struct {
+	Buffered []Message `json:"buffered,omitempty"`
+	// Late are the parents that hadn't delivered when the node last computed. Their next message
+	// belongs to that round and is dropped.
+	Late []string `json:"late,omitempty"`
+}
+
+type waitPolicy struct {
+	mode    string
+	need    int
+	parents []string
+}
+
+// joinGate holds back the messages of waiting nodes until their policy is satisfied
+type joinGate struct {
+	policies map[string]waitPolicy
+	state    map[string]*joinState
+}
+
+// waitMode returns the wait-for policy of a node, or "" if it computes on every message
+func waitMode(node *Node) string {
+	if mode, ok := node.Data["wait_for"].(string); ok && mode != "" {
+		return mode
+	}
+	if node.Type == NodeTypeJoin {
+		return WaitAll
+	}
+	return ""
+}
+
+// parentsOf returns the sorted IDs of the nodes with an edge to id
+func parentsOf(wf *Workflow, id string) []string {
+	seen := map[string]bool{}
+	var parents []string
+	for _, edge := range wf.Edges {
+		if edge.Target == id && edge.Source != id && !seen[edge.Source] {
+			seen[edge.Source] = true
+			parents = append(parents, edge.Source)
+		}
+	}
+	sort.Strings(parents)
+	return parents
+}
+
+func newJoinGate(wf *Workflow, state map[string]*joinState) *joinGate {
+	g := &joinGate{policies: map[string]waitPolicy{}, state: map[string]*joinState{}}
+	for i := range wf.Nodes {
+		node := &wf.Nodes[i]
+		mode := waitMode(node)
+		if mode == "" {
+			continue
+		}
+		p := waitPolicy{mode: mode, parents: parentsOf(wf, node.ID)}
+		switch mode {
+		case WaitAny:
+			p.need = 1
+		case WaitQuorum:
+			if f, ok := node.Data["quorum"].(float64); ok {
+				p.need = int(f)
+			}
+		default:
+			p.need = len(p.parents)
+		}
+		p.need = max(1, min(p.need, len(p.parents)))
+		g.policies[node.ID] = p
+		g.state[node.ID] = &joinState{}
+	}
+	for id, st := range state {
+		if _, ok := g.policies[id]; ok && st != nil {
+			g.state[id] = &joinState{Buffered: append([]Message(nil), st.Buffered...), Late: append([]string(nil), st.Late...)}
+		}
+	}
+	return g
+}
+
+// admit moves the messages of waiting nodes out of inbox into their buffers and puts a node's
+// buffered messages back once enough parents have delivered
+func (g *joinGate) admit(inbox map[string][]Message) {
+	for id, p := range g.policies {
+		msgs, ok := inbox[id]
+		if !ok {
+			continue
+		}
+		delete(inbox, id)
+		st := g.state[id]
+
+		for _, msg := range msgs {
+			if i := indexOf(st.Late, msg.From); i >= 0 {
+				st.Late = append(st.Late[:i], st.Late[i+1:]...)
+				fmt.Printf("Node %s: dropping late message from %s (wait_for=%s)\n", id, msg.From, p.mode)
+				continue
+			}
+			st.Buffered = append(st.Buffered, msg)
+		}
+
+		delivered := map[string]bool{}
+		for _, msg := range st.Buffered {
+			if indexOf(p.parents, msg.From) >= 0 {
+				delivered[msg.From] = true
+			}
+		}
+		if len(delivered) < p.need {
+			if len(st.Buffered) > 0 {
+				fmt.Printf("Node %s waiting: %d of %d parents delivered\n", id, len(delivered), p.need)
+			}
+			continue
+		}
+
+		inbox[id] = st.Buffered
+		st.Buffered = nil
+		st.Late = nil
+		for _, parent := range p.parents {
+			if !delivered[parent] {
+				st.Late = append(st.Late, parent)
+			}
+		}
+	}
+}
+
+// flush releases every buffered message regardless of policy. ExecuteBSP calls it when nothing
+// else is left to compute, since the missing parents can no longer deliver. It reports whether
+// anything was released.
+func (g *joinGate) flush(inbox map[string][]Message) bool {
+	released := false
+	for id, st := range g.state {
+		if len(st.Buffered) == 0 {
+			continue
+		}
+		fmt.Printf("Node %s: releasing %d buffered messages, its remaining parents will not deliver\n", id, len(st.Buffered))
+		inbox[id] = append(inbox[id], st.Buffered...)
+		st.Buffered = nil
+		st.Late = nil
+		released = true
+	}
+	return released
+}
+
+// waiting returns the nodes that have messages held back, sorted
+func (g *joinGate) waiting() []string {
+	var ids []string
+	for id, st := range g.state {
+		if len(st.Buffered) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// snapshot copies the gate state for a checkpoint
+func (g *joinGate) snapshot() map[string]*joinState {
+	state := make(map[string]*joinState, len(g.state))
+	for id, st := range g.state {
+		if len(st.Buffered) > 0 || len(st.Late) > 0 {
+			state[id] = &joinState{Buffered: append([]Message(nil), st.Buffered...), Late: append([]string(nil), st.Late...)}
+		}
+	}
+	return state
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// validateWaitFor checks a node's wait-for policy against its incoming edges
+func validateWaitFor(wf Workflow, node *Node) []string {
+	mode := waitMode(node)
+	switch mode {
+	case "", WaitAll, WaitAny:
+		return nil
+	case WaitQuorum:
+		quorum, ok := node.Data["quorum"].(float64)
+		if !ok || quorum < 1 || quorum != float64(int(quorum)) {
+			return []string{"wait_for \"quorum\" needs a positive integer quorum"}
+		}
+		if parents := parentsOf(&wf, node.ID); int(quorum) > len(parents) {
+			return []string{fmt.Sprintf("quorum %d is larger than the number of parents (%d)", int(quorum), len(parents))}
+		}
+		return nil
+	default:
+		return []string{fmt.Sprintf("wait_for: unknown policy %q, expected %q, %q or %q", mode, WaitAll, WaitAny, WaitQuorum)}
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"testing"
+)
+
+func TestJoinBuffersUntilAllParentsDelivered(t *testing.T) {
+	// a delivers to the join in superstep 1, c only in superstep 2
+	wf := Workflow{
+		ID: "join",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("a", NodeTypeTask, nil),
+			node("b", NodeTypeTask, nil),
+			node("c", NodeTypeTask, nil),
+			node("join", NodeTypeJoin, nil),
+		},
+		Edges: []Edge{edge("start", "a"), edge("start", "b"), edge("b", "c"), edge("a", "join"), edge("c", "join")},
+	}
+	run := newTestRun(nil)
+	cp := &memCheckpointer{}
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{NumWorkers: 2, Checkpointer: cp}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := run.count("join"); n != 1 {
+		t.Fatalf("expected the join to compute once, got %d", n)
+	}
+	got := run.received["join"][0]
+	if len(got) != 2 || got[0].From != "a" || got[1].From != "c" {
+		t.Errorf("expected the messages of a and c, got %+v", got)
+	}
+
+	// While waiting for c, the message of a is held back and part of the checkpoint
+	var buffered bool
+	for _, saved := range cp.saved {
+		if st := saved.Joins["join"]; st != nil && len(st.Buffered) == 1 && st.Buffered[0].From == "a" {
+			buffered = true
+		}
+	}
+	if !buffered {
+		t.Error("expected a checkpoint with the message of a buffered for the join")
+	}
+}
+
+func TestWaitForAnyDropsLateMessages(t *testing.T) {
+	wf := Workflow{
+		ID: "any",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("fast", NodeTypeTask, nil),
+			node("slow", NodeTypeTask, nil),
+			node("slower", NodeTypeTask, nil),
+			node("first", NodeTypeTask, map[string]interface{}{"wait_for": WaitAny}),
+		},
+		Edges: []Edge{edge("start", "fast"), edge("start", "slow"), edge("slow", "slower"), edge("fast", "first"), edge("slower", "first")},
+	}
+	run := newTestRun(nil)
+
+	if err := ExecuteBSP(context.Background(), wf, NewExecutionContext(wf.ID), run.factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := run.count("first"); n != 1 {
+		t.Fatalf("expected the node to compute once, got %d", n)
+	}
+	if got := run.received["first"][0]; len(got) != 1 || got[0].From != "fast" {
+		t.Errorf("expected only the message of fast, got %+v", got)
+	}
+}
+
+func TestCompleteSkipsNodesWaitingOnJoin(t *testing.T) {
+	// a delivers to the join while b completes the run in the same superstep
+	wf := Workflow{
+		ID: "complete",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("a", NodeTypeTask, nil),
+			node("b", NodeTypeTask, nil),
+			node("c", NodeTypeTask, nil),
+			node("join", NodeTypeJoin, nil),
+		},
+		Edges: []Edge{edge("start", "a"), edge("start", "b"), edge("b", "c"), edge("a", "join"), edge("c", "join")},
+	}
+	run := newTestRun(map[string]computeFunc{
+		"b": func(ctx *Context, messages []Message) error {
+			ctx.Complete()
+			return nil
+		},
+	})
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if run.count("join") != 0 || run.count("c") != 0 {
+		t.Fatalf("expected nothing to compute after completion, got join=%d c=%d", run.count("join"), run.count("c"))
+	}
+	if execCtx.Status["join"] != StatusSkipped {
+		t.Errorf("expected the join holding a's message to be %s, got %q", StatusSkipped, execCtx.Status["join"])
+	}
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultLoopIterations bounds a LOOP node that doesn't set max_iterations
+const DefaultLoopIterations = 10
+
+// LoopIteration returns how many times a loop node has entered its body since it was last
+// entered from outside. Safe for concurrent use.
+func (e *ExecutionContext) LoopIteration(nodeID string) int {
+	state, ok := e.LoadNodeState(nodeID)
+	if !ok {
+		return 0
+	}
+	m, ok := state.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	n, _ := toFloat(m["iteration"])
+	return int(n)
+}
+
+// SetLoopIteration records the iteration counter of a loop node; 0 resets it. Safe for concurrent use.
+func (e *ExecutionContext) SetLoopIteration(nodeID string, iteration int) {
+	if iteration == 0 {
+		e.StoreNodeState(nodeID, nil)
+		return
+	}
+	e.StoreNodeState(nodeID, map[string]interface{}{"iteration": iteration})
+}
+
+// LoopBody returns the IDs of the nodes in the body of a loop node: those reachable from it
+// through its body edges without passing through the loop again
+func LoopBody(wf *Workflow, loopID string) map[string]bool {
+	body := map[string]bool{}
+	queue := []string{}
+	for _, edge := range wf.Edges {
+		if edge.Source == loopID && edge.SourceHandle != LoopExitHandle && edge.SourceHandle != ErrorHandle {
+			queue = append(queue, edge.Target)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == loopID || body[id] {
+			continue
+		}
+		body[id] = true
+		for _, edge := range wf.Edges {
+			if edge.Source == id {
+				queue = append(queue, edge.Target)
+			}
+		}
+	}
+	return body
+}
+
+// runningLoops describes the loop nodes that were still iterating, for superstep limit errors
+func runningLoops(wf *Workflow, execCtx *ExecutionContext) string {
+	var loops []string
+	for i := range wf.Nodes {
+		node := &wf.Nodes[i]
+		if node.Type != NodeTypeLoop {
+			continue
+		}
+		if n := execCtx.LoopIteration(node.ID); n > 0 {
+			loops = append(loops, fmt.Sprintf("%q (node %s, iteration %d)", node.Label(), node.ID, n))
+		}
+	}
+	sort.Strings(loops)
+	return strings.Join(loops, ", ")
+}
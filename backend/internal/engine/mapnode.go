@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// Inputs each per-item run of a MAP node's inline node receives
+const (
+	MapItemInput  = "item"
+	MapIndexInput = "index"
+)
+
+// DefaultMapConcurrency bounds how many items a MAP node processes at once when data.concurrency isn't set
+const DefaultMapConcurrency = 4
+
+// Partial failure policies a MAP node selects with data.on_item_error
+const (
+	MapFailAll  = "fail" // fail the node if any item fails (default)
+	MapSkipItem = "skip" // drop failed items from the results
+	MapKeepNull = "null" // keep a nil result in the failed item's position
+)
+
+// MapInnerWorkflow builds the one-node workflow a MAP node of wf runs per item from its inline
+// data.node definition ({"type": ..., "data": {...}}). The inner node can use {{inputs.item}}
+// and {{inputs.index}} in its templates.
+func MapInnerWorkflow(wf *Workflow, mapNode *Node) (*Workflow, error) {
+	def, ok := mapNode.Data["node"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("node: expected an object with the inner node's type and data")
+	}
+	nodeType, _ := def["type"].(string)
+	if nodeType == "" {
+		return nil, fmt.Errorf("node: missing type")
+	}
+	data, _ := def["data"].(map[string]interface{})
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	return &Workflow{
+		ID:    wf.ID + "/" + mapNode.ID + "/" + nodeType,
+		Nodes: []Node{{ID: mapNode.ID + "-item", Type: NodeType(nodeType), Data: data}},
+		Inputs: []InputParam{
+			{Name: MapItemInput, Type: "any", Description: "The list item being processed"},
+			{Name: MapIndexInput, Type: "integer", Description: "Position of the item in the list"},
+		},
+	}, nil
+}
+
+// validateMap checks a MAP node's items expression, inner node and policies
+func validateMap(wf Workflow, node *Node, nodes map[string]*Node, adj map[string][]string, registry *Registry) []string {
+	if node.Type != NodeTypeMap {
+		return nil
+	}
+
+	var msgs []string
+	if expr, ok := node.Data["items"].(string); ok && expr != "" {
+		if msg := validateExpression(wf, node, nodes, adj, expr); msg != "" {
+			msgs = append(msgs, "items: "+msg)
+		}
+	}
+
+	_, hasWorkflow := node.Data["workflow_id"]
+	_, hasNode := node.Data["node"]
+	switch {
+	case hasWorkflow && hasNode:
+		msgs = append(msgs, "set either workflow_id or node, not both")
+	case !hasWorkflow && !hasNode:
+		msgs = append(msgs, "set workflow_id or node to choose what runs for each item")
+	case hasWorkflow:
+		if target, _ := node.Data["workflow_id"].(string); target == wf.ID {
+			msgs = append(msgs, "a workflow cannot run itself for each item")
+		}
+	case hasNode:
+		inner, err := MapInnerWorkflow(&wf, node)
+		if err != nil {
+			msgs = append(msgs, err.Error())
+			break
+		}
+		if inner.Nodes[0].Type == NodeTypeMap {
+			msgs = append(msgs, "node: MAP nodes cannot be nested inline, use a saved workflow instead")
+			break
+		}
+		if verr, ok := Validate(*inner, registry).(*ValidationError); ok {
+			for _, issue := range verr.Issues {
+				msgs = append(msgs, "node: "+issue.Message)
+			}
+		}
+	}
+
+	switch policy, _ := node.Data["on_item_error"].(string); policy {
+	case "", MapFailAll, MapSkipItem, MapKeepNull:
+	default:
+		msgs = append(msgs, fmt.Sprintf("on_item_error: unknown policy %q, expected %q, %q or %q", policy, MapFailAll, MapSkipItem, MapKeepNull))
+	}
+	return msgs
+}
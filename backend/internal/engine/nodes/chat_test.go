@@ -0,0 +1,98 @@
+package nodes
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/llm"
+)
+
+// memoryTranscripts keeps chat sessions in memory
+type memoryTranscripts struct {
+	mu       sync.Mutex
+	sessions map[string][]llm.ChatMessage
+}
+
+func (m *memoryTranscripts) LoadTranscript(ctx context.Context, session string) ([]llm.ChatMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]llm.ChatMessage(nil), m.sessions[session]...), nil
+}
+
+func (m *memoryTranscripts) SaveTranscript(ctx context.Context, session string, transcript []llm.ChatMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session] = append([]llm.ChatMessage(nil), transcript...)
+	return nil
+}
+
+func chatWorkflow(data map[string]interface{}) engine.Workflow {
+	return engine.Workflow{
+		ID:    "support",
+		Nodes: []engine.Node{node("start", engine.NodeTypeStart, nil), node("chat", engine.NodeTypeChat, data)},
+		Edges: []engine.Edge{edge("start", "chat")},
+	}
+}
+
+func TestChatContinuesStoredSession(t *testing.T) {
+	store := &memoryTranscripts{sessions: map[string][]llm.ChatMessage{}}
+	client := echoLLM()
+	client.reply = func(req llm.Request) (string, error) {
+		return "re: " + req.Messages[len(req.Messages)-1].Content, nil
+	}
+	wf := chatWorkflow(map[string]interface{}{"prompt": "{{inputs.question}}", "session": "{{inputs.user}}"})
+	deps := Dependencies{LLM: client, Transcripts: store}
+
+	for _, question := range []string{"hello", "what did I say?"} {
+		if _, err := runWorkflow(wf, map[string]interface{}{"user": "ada", "question": question}, deps); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(client.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(client.requests))
+	}
+	var sent []string
+	for _, msg := range client.requests[1].Messages {
+		sent = append(sent, msg.Role+": "+msg.Content)
+	}
+	want := "user: hello|assistant: re: hello|user: what did I say?"
+	if got := strings.Join(sent, "|"); got != want {
+		t.Errorf("expected the second run to continue the conversation %q, got %q", want, got)
+	}
+	if n := len(store.sessions["support:ada"]); n != 4 {
+		t.Errorf("expected the session to hold 4 turns, got %d", n)
+	}
+}
+
+func TestChatTrimsToContextWindow(t *testing.T) {
+	long := strings.Repeat("word ", 40) // 50 tokens
+	store := &memoryTranscripts{sessions: map[string][]llm.ChatMessage{
+		"support:ada": {
+			{Role: llm.RoleUser, Content: long},
+			{Role: llm.RoleAssistant, Content: long},
+			{Role: llm.RoleUser, Content: long},
+			{Role: llm.RoleAssistant, Content: long},
+		},
+	}}
+	client := &scriptedLLM{}
+	wf := chatWorkflow(map[string]interface{}{"prompt": "and now?", "session": "ada", "context_window": float64(160)})
+
+	execCtx, err := runWorkflow(wf, nil, Dependencies{LLM: client, Transcripts: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 160 tokens less a quarter for the reply leaves room for two long turns and the question
+	sent := client.requests[0].Messages
+	if len(sent) != 3 || sent[0].Role != llm.RoleUser || sent[2].Content != "and now?" {
+		t.Errorf("expected the oldest turns to be trimmed, got %+v", sent)
+	}
+	result, _ := execCtx.GetResult("chat")
+	if turns := result.(map[string]interface{})["turns"]; turns != 6 {
+		t.Errorf("expected the full transcript to be kept, got %v turns", turns)
+	}
+}
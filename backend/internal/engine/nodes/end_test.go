@@ -0,0 +1,118 @@
+package nodes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/llm"
+)
+
+// greekLLM answers prompts starting with A, B or C with alpha, beta or gamma
+func greekLLM() *scriptedLLM {
+	return &scriptedLLM{reply: func(req llm.Request) (string, error) {
+		switch {
+		case strings.HasPrefix(req.Prompt, "A"):
+			return "alpha", nil
+		case strings.HasPrefix(req.Prompt, "B"):
+			return "beta", nil
+		default:
+			return "gamma", nil
+		}
+	}}
+}
+
+// twoBranches feeds sink from a (reaching it at superstep 2) and from b -> c (at superstep 3)
+func twoBranches(sink engine.Node) engine.Workflow {
+	return engine.Workflow{
+		ID: "branches",
+		Nodes: []engine.Node{
+			node("start", engine.NodeTypeStart, nil),
+			node("a", engine.NodeTypeLLM, map[string]interface{}{"prompt": "A"}),
+			node("b", engine.NodeTypeLLM, map[string]interface{}{"prompt": "B"}),
+			node("c", engine.NodeTypeLLM, map[string]interface{}{"prompt": "C"}),
+			sink,
+		},
+		Edges: []engine.Edge{edge("start", "a"), edge("start", "b"), edge("b", "c"), edge("a", sink.ID), edge("c", sink.ID)},
+	}
+}
+
+func TestResultCollectsAcrossSupersteps(t *testing.T) {
+	tests := []struct {
+		merge, format string
+		wantResult    string
+		wantInputs    interface{}
+	}{
+		{"keyed", "text", "alpha\n\ngamma", map[string]interface{}{"a": "alpha", "c": "gamma"}},
+		{"list", "json", "[\n  \"alpha\",\n  \"gamma\"\n]", []interface{}{"alpha", "gamma"}},
+		{"keyed", "markdown", "## a\n\nalpha\n\n## c\n\ngamma", map[string]interface{}{"a": "alpha", "c": "gamma"}},
+	}
+	for _, tt := range tests {
+		sink := node("result", engine.NodeTypeResult, map[string]interface{}{"merge": tt.merge, "format": tt.format})
+		execCtx, err := runWorkflow(twoBranches(sink), nil, Dependencies{LLM: greekLLM()})
+		if err != nil {
+			t.Fatalf("%s/%s: %v", tt.merge, tt.format, err)
+		}
+
+		result, _ := execCtx.GetResult("result")
+		m := result.(map[string]interface{})
+		if m["result"] != tt.wantResult {
+			t.Errorf("%s/%s: expected result %q, got %q", tt.merge, tt.format, tt.wantResult, m["result"])
+		}
+		if !reflect.DeepEqual(m["inputs"], tt.wantInputs) {
+			t.Errorf("%s/%s: expected inputs %v, got %v", tt.merge, tt.format, tt.wantInputs, m["inputs"])
+		}
+	}
+}
+
+func TestEndCollectsOutputsOfAllBranches(t *testing.T) {
+	execCtx, err := runWorkflow(twoBranches(node("end", engine.NodeTypeEnd, nil)), nil, Dependencies{LLM: greekLLM()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{"a": "alpha", "c": "gamma"}
+	end, _ := execCtx.GetResult("end")
+	if m := end.(map[string]interface{}); !reflect.DeepEqual(m["outputs"], want) || m["result"] != "gamma" || m["completed"] != true {
+		t.Errorf("expected the END node to collect %v with the last result, got %v", want, m)
+	}
+	if !reflect.DeepEqual(execCtx.Outputs, want) {
+		t.Errorf("expected the run outputs %v, got %v", want, execCtx.Outputs)
+	}
+}
+
+func TestEndTerminateSkipsPendingBranches(t *testing.T) {
+	// a reaches the END node at superstep 2, while the b -> c -> d branch is still running
+	wf := engine.Workflow{
+		ID: "terminate",
+		Nodes: []engine.Node{
+			node("start", engine.NodeTypeStart, nil),
+			node("a", engine.NodeTypeLLM, map[string]interface{}{"prompt": "A"}),
+			node("b", engine.NodeTypeLLM, map[string]interface{}{"prompt": "B"}),
+			node("c", engine.NodeTypeLLM, map[string]interface{}{"prompt": "C"}),
+			node("d", engine.NodeTypeLLM, map[string]interface{}{"prompt": "D"}),
+			node("end", engine.NodeTypeEnd, map[string]interface{}{"terminate": true}),
+		},
+		Edges: []engine.Edge{edge("start", "a"), edge("start", "b"), edge("b", "c"), edge("c", "d"), edge("a", "end")},
+	}
+	client := greekLLM()
+
+	execCtx, err := runWorkflow(wf, nil, Dependencies{LLM: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := execCtx.Status["d"]; got != engine.StatusSkipped {
+		t.Errorf("expected the pending node d to be %s, got %s", engine.StatusSkipped, got)
+	}
+	for _, prompt := range client.prompts() {
+		if strings.HasPrefix(prompt, "D") {
+			t.Error("expected node d not to call the model after the run completed")
+		}
+	}
+	end, _ := execCtx.GetResult("end")
+	if m := end.(map[string]interface{}); !reflect.DeepEqual(m["outputs"], map[string]interface{}{"a": "alpha"}) {
+		t.Errorf("expected the END node to hold only a's output, got %v", m)
+	}
+}
@@ -0,0 +1,48 @@
+package nodes
+
+import (
+	"testing"
+
+	"workflow-platform/internal/config"
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/llm"
+)
+
+// recordingClient remembers the provider config it was created with
+type recordingClient struct {
+	llm.MockClient
+	cfg llm.ProviderConfig
+}
+
+func TestLLMClientUsesServerProviderForNodesWithoutOne(t *testing.T) {
+	providers := llm.NewRegistry(config.LLMConfig{Provider: "fake", Model: "fake-model"})
+	providers.Register("fake", func(cfg llm.ProviderConfig) (llm.Client, error) {
+		return &recordingClient{cfg: cfg}, nil
+	})
+	fallback := &llm.MockClient{}
+	v := &LLMVertex{Client: fallback, Providers: providers}
+	n := &engine.Node{ID: "llm", Type: engine.NodeTypeLLM, Data: map[string]interface{}{}}
+
+	// A key for another provider doesn't change the client
+	wf := &engine.Workflow{Config: map[string]string{"openai_api_key": "sk-openai"}}
+	client, err := v.client(n, wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != fallback {
+		t.Errorf("expected the default client, got %#v", client)
+	}
+
+	wf = &engine.Workflow{Config: map[string]string{"fake_api_key": "sk-fake"}}
+	client, err = v.client(n, wf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, ok := client.(*recordingClient)
+	if !ok {
+		t.Fatalf("expected a client of the server's provider, got %#v", client)
+	}
+	if rec.cfg.APIKey != "sk-fake" || rec.cfg.Model != "fake-model" {
+		t.Errorf("expected the workflow key and the server model, got %+v", rec.cfg)
+	}
+}
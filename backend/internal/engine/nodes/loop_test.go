@@ -0,0 +1,126 @@
+package nodes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/llm"
+)
+
+// draftLoop revises a draft in the loop body and leaves through the exit handle to "done"
+func draftLoop(loopData map[string]interface{}) engine.Workflow {
+	exit := edge("loop", "done")
+	exit.SourceHandle = engine.LoopExitHandle
+	return engine.Workflow{
+		ID: "drafts",
+		Nodes: []engine.Node{
+			node("start", engine.NodeTypeStart, nil),
+			node("loop", engine.NodeTypeLoop, loopData),
+			node("draft", engine.NodeTypeLLM, map[string]interface{}{"prompt": "Improve the draft"}),
+			node("done", engine.NodeTypeEnd, nil),
+		},
+		Edges: []engine.Edge{edge("start", "loop"), edge("loop", "draft"), edge("draft", "loop"), exit},
+	}
+}
+
+// countingLLM replies "draft 1", "draft 2", ... and "final" from the given call on
+func countingLLM(finalAt int) *scriptedLLM {
+	client := &scriptedLLM{}
+	calls := 0
+	client.reply = func(req llm.Request) (string, error) {
+		calls++
+		if finalAt > 0 && calls >= finalAt {
+			return "final", nil
+		}
+		return fmt.Sprintf("draft %d", calls), nil
+	}
+	return client
+}
+
+func TestLoopRunsBodyUntilExitCondition(t *testing.T) {
+	client := countingLLM(3)
+	wf := draftLoop(map[string]interface{}{
+		"max_iterations": float64(5),
+		"exit_condition": map[string]interface{}{"operator": "equals", "value": "final"},
+	})
+
+	execCtx, err := runWorkflow(wf, nil, Dependencies{LLM: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(client.prompts()); n != 3 {
+		t.Errorf("expected the body to run 3 times, got %d", n)
+	}
+	loop, _ := execCtx.GetResult("loop")
+	if m := loop.(map[string]interface{}); m["exited"] != true || m["iteration"] != 3 {
+		t.Errorf("expected the loop to exit after 3 iterations, got %v", m)
+	}
+	done, _ := execCtx.GetResult("done")
+	if got := done.(map[string]interface{})["result"]; got != "final" {
+		t.Errorf("expected the exit branch to receive the final draft, got %v", got)
+	}
+	if n := execCtx.LoopIteration("loop"); n != 0 {
+		t.Errorf("expected the iteration counter to be reset on exit, got %d", n)
+	}
+}
+
+func TestLoopWithoutExitConditionRunsMaxIterations(t *testing.T) {
+	client := countingLLM(0)
+	execCtx, err := runWorkflow(draftLoop(map[string]interface{}{"max_iterations": float64(2)}), nil, Dependencies{LLM: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(client.prompts()); n != 2 {
+		t.Errorf("expected the body to run 2 times, got %d", n)
+	}
+	done, _ := execCtx.GetResult("done")
+	if got := done.(map[string]interface{})["result"]; got != "draft 2" {
+		t.Errorf("expected the exit branch to receive the last draft, got %v", got)
+	}
+}
+
+func TestLoopFailsWhenExitConditionIsNeverMet(t *testing.T) {
+	wf := draftLoop(map[string]interface{}{
+		"max_iterations": float64(2),
+		"exit_condition": map[string]interface{}{"operator": "equals", "value": "final"},
+	})
+
+	execCtx, err := runWorkflow(wf, nil, Dependencies{LLM: countingLLM(0)})
+	if err == nil || !strings.Contains(err.Error(), "exceeded its limit of 2 iterations") {
+		t.Fatalf("expected the loop to fail after 2 iterations, got %v", err)
+	}
+	if _, ran := execCtx.GetResult("done"); ran {
+		t.Error("expected the exit branch not to run")
+	}
+}
+
+func TestLoopPrefersBodyOverOutsideMessages(t *testing.T) {
+	// "outside" reaches the loop in the same superstep as the first draft
+	wf := draftLoop(map[string]interface{}{
+		"exit_condition": map[string]interface{}{"operator": "equals", "value": "draft"},
+	})
+	wf.Nodes = append(wf.Nodes,
+		node("relay", engine.NodeTypeLLM, map[string]interface{}{"prompt": "Relay"}),
+		node("outside", engine.NodeTypeLLM, map[string]interface{}{"prompt": "Outside"}))
+	wf.Edges = append(wf.Edges, edge("start", "relay"), edge("relay", "outside"), edge("outside", "loop"))
+	client := &scriptedLLM{reply: func(req llm.Request) (string, error) {
+		if strings.HasPrefix(req.Prompt, "Improve") {
+			return "draft", nil
+		}
+		return "outside", nil
+	}}
+
+	execCtx, err := runWorkflow(wf, nil, Dependencies{LLM: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loop, _ := execCtx.GetResult("loop")
+	if m := loop.(map[string]interface{}); m["result"] != "draft" || m["iteration"] != 1 {
+		t.Errorf("expected the body's result to win and end the loop after one iteration, got %v", m)
+	}
+}
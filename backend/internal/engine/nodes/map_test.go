@@ -0,0 +1,145 @@
+package nodes
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/llm"
+)
+
+// mapWorkflow lists the items with the "split" node and maps them with the MAP node's data
+func mapWorkflow(mapData map[string]interface{}) engine.Workflow {
+	return engine.Workflow{
+		ID: "mapper",
+		Nodes: []engine.Node{
+			node("start", engine.NodeTypeStart, nil),
+			node("split", engine.NodeTypeLLM, map[string]interface{}{"prompt": "List"}),
+			node("map", engine.NodeTypeMap, mapData),
+		},
+		Edges: []engine.Edge{edge("start", "split"), edge("split", "map")},
+	}
+}
+
+// inlineUpper is a MAP inner node asking the model to upper-case each item
+var inlineUpper = map[string]interface{}{
+	"type": "LLM",
+	"data": map[string]interface{}{"prompt": "Upper {{inputs.item}}"},
+}
+
+// itemLLM lists the items as a JSON array and upper-cases each "Upper <item>" prompt,
+// failing for the item "bad"
+func itemLLM(items string) *scriptedLLM {
+	return &scriptedLLM{reply: func(req llm.Request) (string, error) {
+		item, ok := strings.CutPrefix(req.Prompt, "Upper ")
+		if !ok {
+			return items, nil
+		}
+		if item == "bad" {
+			return "", errors.New("cannot upper-case bad")
+		}
+		return strings.ToUpper(item), nil
+	}}
+}
+
+func mapResult(t *testing.T, execCtx *engine.ExecutionContext) map[string]interface{} {
+	t.Helper()
+	result, ok := execCtx.GetResult("map")
+	if !ok {
+		t.Fatal("expected the MAP node to have a result")
+	}
+	return result.(map[string]interface{})
+}
+
+func TestMapRunsInnerNodePerItemInOrder(t *testing.T) {
+	client := itemLLM(`["a", "b", "c", "d", "e", "f"]`)
+	var running, peak int32
+	reply := client.reply
+	client.reply = func(req llm.Request) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return reply(req)
+	}
+
+	execCtx, err := runWorkflow(mapWorkflow(map[string]interface{}{"node": inlineUpper, "concurrency": float64(2)}), nil, Dependencies{LLM: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []interface{}{"A", "B", "C", "D", "E", "F"}
+	if got := mapResult(t, execCtx); !reflect.DeepEqual(got["result"], want) || got["count"] != 6 {
+		t.Errorf("expected %v in item order, got %v", want, got)
+	}
+	if p := atomic.LoadInt32(&peak); p != 2 {
+		t.Errorf("expected 2 items to run at a time, got %d", p)
+	}
+}
+
+func TestMapItemErrorPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []interface{}
+	}{
+		{engine.MapSkipItem, []interface{}{"A", "C"}},
+		{engine.MapKeepNull, []interface{}{"A", nil, "C"}},
+	}
+	for _, tt := range tests {
+		wf := mapWorkflow(map[string]interface{}{"node": inlineUpper, "on_item_error": tt.policy})
+		execCtx, err := runWorkflow(wf, nil, Dependencies{LLM: itemLLM(`["a", "bad", "c"]`)})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.policy, err)
+		}
+		got := mapResult(t, execCtx)
+		if !reflect.DeepEqual(got["result"], tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.policy, tt.want, got["result"])
+		}
+		if errs := got["errors"].([]interface{}); len(errs) != 1 || errs[0].(map[string]interface{})["index"] != 1 {
+			t.Errorf("%s: expected the error of item 1 to be reported, got %v", tt.policy, errs)
+		}
+	}
+
+	_, err := runWorkflow(mapWorkflow(map[string]interface{}{"node": inlineUpper}), nil, Dependencies{LLM: itemLLM(`["a", "bad"]`)})
+	if err == nil || !strings.Contains(err.Error(), "item 1") {
+		t.Errorf("expected a failed item to fail the node by default, got %v", err)
+	}
+}
+
+func TestMapRunsSavedWorkflowPerItem(t *testing.T) {
+	wf := mapWorkflow(map[string]interface{}{"workflow_id": "summarizer", "item_input": "text"})
+	client := itemLLM(`["one", "two"]`)
+	reply := client.reply
+	client.reply = func(req llm.Request) (string, error) {
+		if text, ok := strings.CutPrefix(req.Prompt, "Summarize "); ok {
+			return "short " + text, nil
+		}
+		return reply(req)
+	}
+
+	execCtx, err := runWorkflow(wf, nil, Dependencies{LLM: client, Workflows: mapLoader{"summarizer": summarizer}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []interface{}{"short one", "short two"}
+	if got := mapResult(t, execCtx)["result"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestMapRejectsNonListItems(t *testing.T) {
+	_, err := runWorkflow(mapWorkflow(map[string]interface{}{"node": inlineUpper}), nil, Dependencies{LLM: itemLLM("not a list")})
+	if err == nil || !strings.Contains(err.Error(), "expected a list of items") {
+		t.Errorf("expected the run to fail on a non-list, got %v", err)
+	}
+}
@@ -2,7 +2,10 @@ package nodes
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"workflow-platform/internal/engine"
@@ -12,19 +15,80 @@ import (
 // LLMVertex simulates an LLM invocation
 type LLMVertex struct {
 	Client llm.Client
+	// Providers serves nodes that set data.provider or data.base_url
+	Providers *llm.Registry
+	// Loader and Registry run the saved workflows offered as tools in data.tools
+	Loader   WorkflowLoader
+	Registry *engine.Registry
 }
 
-func (v *LLMVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
-	// 1. Check if we have inputs (either from trigger or previous nodes)
-	fmt.Printf("[LLMVertex %s] Computing at step %d. Messages: %d\n", ctx.NodeID, ctx.Step, len(messages))
+// client returns the LLM client a node uses: Client unless the node selects a provider or
+// the workflow config carries an API key for it as <provider>_api_key. Nodes without a
+// provider run on the server's provider (LLM_PROVIDER) and use its key from the config.
+func (v *LLMVertex) client(node *engine.Node, wf *engine.Workflow) (llm.Client, error) {
+	provider, _ := node.Data["provider"].(string)
+	baseURL, _ := node.Data["base_url"].(string)
+	if provider == "" && baseURL == "" {
+		if v.Providers == nil {
+			return v.Client, nil
+		}
+		apiKey := wf.Config[v.Providers.DefaultProvider()+"_api_key"]
+		if apiKey == "" {
+			return v.Client, nil
+		}
+		return v.Providers.Client(llm.ProviderConfig{APIKey: apiKey})
+	}
+	if v.Providers == nil {
+		return nil, fmt.Errorf("no LLM providers configured for provider %q", provider)
+	}
+	if provider == "" {
+		provider = llm.ProviderOpenAICompatible
+	}
+	return v.Providers.Client(llm.ProviderConfig{Provider: provider, BaseURL: baseURL, APIKey: wf.Config[provider+"_api_key"]})
+}
 
-	// Simulate processing inputs to form a prompt
-	var inputData string
-	for _, msg := range messages {
-		if val, ok := msg.Content["result"]; ok {
-			inputData += fmt.Sprintf("%v ", val)
+// legacyModelNames are display names older versions of the editor stored in data.model.
+// They don't name a provider model, so nodes using them run on the server's default model.
+var legacyModelNames = map[string]bool{"GPT-4": true, "GPT-3.5": true, "Gemini": true, "Claude": true}
+
+// llmRequest builds the request of an LLM node from its data. Settings the node leaves
+// unset are filled in by the client from the server's LLM configuration.
+func llmRequest(data map[string]interface{}, prompt, system string) llm.Request {
+	req := llm.Request{Prompt: prompt, System: system}
+	if model, _ := data["model"].(string); !legacyModelNames[model] {
+		req.Model = model
+	}
+	if v, ok := data["temperature"].(float64); ok {
+		req.Temperature = &v
+	}
+	if v, ok := data["top_p"].(float64); ok {
+		req.TopP = &v
+	}
+	if v, ok := data["max_tokens"].(float64); ok {
+		req.MaxTokens = int(v)
+	}
+	if v, ok := data["seed"].(float64); ok {
+		seed := int(v)
+		req.Seed = &seed
+	}
+	switch stop := data["stop"].(type) {
+	case string:
+		if stop != "" {
+			req.Stop = []string{stop}
+		}
+	case []interface{}:
+		for _, s := range stop {
+			if str, ok := s.(string); ok && str != "" {
+				req.Stop = append(req.Stop, str)
+			}
 		}
 	}
+	return req
+}
+
+func (v *LLMVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	// 1. Check if we have inputs (either from trigger or previous nodes)
+	fmt.Printf("[LLMVertex %s] Computing at step %d. Messages: %d\n", ctx.NodeID, ctx.Step, len(messages))
 
 	// Retrieve prompt from node data
 	var prompt string
@@ -41,53 +105,1052 @@ func (v *LLMVertex) Compute(ctx *engine.Context, messages []engine.Message) erro
 		}
 	}
 
-	// Combine prompt and inputs
-	fullInput := prompt
-	if inputData != "" {
-		fullInput = fmt.Sprintf("%s\nContext: %s", prompt, inputData)
+	fullInput, err := renderPrompt(ctx, messages, prompt)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(fullInput) == "" {
+		return fmt.Errorf("prompt is empty")
 	}
 
-	if fullInput == "" {
-		fullInput = "Hello" // Default prompt if nothing provided
+	node := ctx.Node()
+	system, err := renderSystemPrompt(ctx, messages, node)
+	if err != nil {
+		return err
 	}
 
 	// Call LLM
+	client, err := v.client(node, ctx.Workflow)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("[LLMVertex %s] Calling LLM with prompt: %s\n", ctx.NodeID, fullInput)
-	result, err := v.Client.Generate(context.Background(), fullInput)
+	req := llmRequest(node.Data, fullInput, system)
+	output := map[string]interface{}{
+		"debug_prompt": fullInput,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+
+	tools, err := engine.DecodeTools(node.Data["tools"])
 	if err != nil {
-		return fmt.Errorf("LLM generation failed: %w", err)
+		return err
+	}
+	schemaData, structured := node.Data["output_schema"].(map[string]interface{})
+
+	var result interface{}
+	if len(tools) > 0 {
+		if structured {
+			return fmt.Errorf("output_schema cannot be combined with tools")
+		}
+		text, calls, err := v.generateWithTools(ctx, client, req, tools, toolIterations(node))
+		output["tool_calls"] = calls
+		if err != nil {
+			ctx.Execution.SetResult(ctx.NodeID, output)
+			return err
+		}
+		result = text
+	} else if structured {
+		parsed, err := generateStructured(ctx.Ctx, client, req, schemaData, repairAttempts(node))
+		if err != nil {
+			return err
+		}
+		result = parsed.value
+		output["raw"], output["repairs"] = parsed.raw, parsed.repairs
+	} else {
+		text, err := client.Generate(ctx.Ctx, req)
+		if err != nil {
+			return fmt.Errorf("LLM generation failed: %w", err)
+		}
+		result = text
 	}
 
 	// Store result in ExecutionContext for frontend debugging
-	ctx.Execution.Results[ctx.NodeID] = map[string]interface{}{
-		"result":       result,
-		"debug_prompt": fullInput,
-		"timestamp":    time.Now().Format(time.RFC3339),
+	output["result"] = result
+	ctx.Execution.SetResult(ctx.NodeID, output)
+
+	// Send result to all children whose edge condition matches
+	if err := ctx.SendToChildren(map[string]interface{}{
+		"result": result,
+	}); err != nil {
+		return fmt.Errorf("routing failed: %w", err)
 	}
 
-	// Send result to all children
-	for _, edge := range ctx.Workflow.Edges {
-		if edge.Source == ctx.NodeID {
-			ctx.SendMessage(edge.Target, map[string]interface{}{
-				"result": result,
-			})
+	return nil
+}
+
+// DefaultRepairAttempts is how often an LLM node with data.output_schema re-prompts the model
+// after an invalid reply when it doesn't set data.repair_attempts
+const DefaultRepairAttempts = 2
+
+func repairAttempts(node *engine.Node) int {
+	if n, ok := node.Data["repair_attempts"].(float64); ok && n >= 0 {
+		return int(n)
+	}
+	return DefaultRepairAttempts
+}
+
+// structuredReply is a reply that was parsed and validated against an output schema
+type structuredReply struct {
+	value   interface{}
+	raw     string
+	repairs int
+}
+
+// generateStructured asks for a reply matching schemaData and validates it. An invalid reply is
+// answered with its validation errors and a request to correct it, up to attempts times.
+func generateStructured(ctx context.Context, client llm.Client, req llm.Request, schemaData map[string]interface{}, attempts int) (*structuredReply, error) {
+	raw, err := json.Marshal(schemaData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output schema: %w", err)
+	}
+	var schema engine.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("invalid output schema: %w", err)
+	}
+	req.ResponseSchema = raw
+	req.Messages, req.Prompt = req.Conversation(), ""
+
+	for repairs := 0; ; repairs++ {
+		text, err := client.Generate(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("LLM generation failed: %w", err)
+		}
+
+		var problems []string
+		value, err := llm.ParseJSON(text)
+		if err != nil {
+			problems = []string{err.Error()}
+		} else {
+			problems = schema.Validate(value)
+		}
+		if len(problems) == 0 {
+			return &structuredReply{value: value, raw: text, repairs: repairs}, nil
+		}
+		if repairs >= attempts {
+			return nil, fmt.Errorf("LLM output does not match the output schema after %d repairs: %s", repairs, strings.Join(problems, "; "))
+		}
+
+		req.Messages = append(req.Messages,
+			llm.ChatMessage{Role: llm.RoleAssistant, Content: text},
+			llm.ChatMessage{Role: llm.RoleUser, Content: "Your reply does not match the required JSON Schema:\n- " +
+				strings.Join(problems, "\n- ") + "\nReply again with only the corrected JSON."},
+		)
+	}
+}
+
+func toolIterations(node *engine.Node) int {
+	if n, ok := node.Data["max_tool_iterations"].(float64); ok && n > 0 {
+		return int(n)
+	}
+	return engine.DefaultToolIterations
+}
+
+// generateWithTools runs the tool-call loop: while the model requests tool calls, the tools run
+// and their results are sent back, for at most iterations replies. Failed tool runs are reported
+// to the model as the call's result. Every call is returned in order, also on error.
+func (v *LLMVertex) generateWithTools(ctx *engine.Context, client llm.Client, req llm.Request, tools []engine.ToolSpec, iterations int) (string, []interface{}, error) {
+	calls := []interface{}{}
+	toolClient, ok := client.(llm.ToolClient)
+	if !ok {
+		return "", calls, fmt.Errorf("the LLM provider does not support tool calling")
+	}
+
+	byName := make(map[string]engine.ToolSpec, len(tools))
+	for _, tool := range tools {
+		params, err := v.toolParameters(ctx, tool)
+		if err != nil {
+			return "", calls, err
+		}
+		req.Tools = append(req.Tools, llm.Tool{Name: tool.Name, Description: tool.Description, Parameters: params})
+		byName[tool.Name] = tool
+	}
+	req.Messages, req.Prompt = req.Conversation(), ""
+
+	for iteration := 1; iteration <= iterations; iteration++ {
+		resp, err := toolClient.GenerateWithTools(ctx.Ctx, req)
+		if err != nil {
+			return "", calls, fmt.Errorf("LLM generation failed: %w", err)
+		}
+		if len(resp.ToolCalls) == 0 {
+			return resp.Content, calls, nil
+		}
+
+		req.Messages = append(req.Messages, llm.ChatMessage{Role: llm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			fmt.Printf("[LLMVertex %s] Calling tool %s\n", ctx.NodeID, call.Name)
+			record := map[string]interface{}{"iteration": iteration, "tool": call.Name, "arguments": call.Arguments}
+			value, err := v.runTool(ctx, byName, call)
+			var content string
+			if err != nil {
+				record["error"] = err.Error()
+				content = "Error: " + err.Error()
+			} else {
+				record["result"] = value
+				content = toolResultText(value)
+			}
+			calls = append(calls, record)
+			req.Messages = append(req.Messages, llm.ChatMessage{Role: llm.RoleTool, Content: content, ToolCallID: call.ID, Name: call.Name})
+		}
+	}
+	return "", calls, fmt.Errorf("the model was still calling tools after %d iterations", iterations)
+}
+
+// toolParameters returns the JSON Schema of a tool's arguments
+func (v *LLMVertex) toolParameters(ctx *engine.Context, tool engine.ToolSpec) (json.RawMessage, error) {
+	schema := tool.Parameters
+	if schema == nil && tool.WorkflowID != "" {
+		wf, err := v.loadTool(ctx, tool)
+		if err != nil {
+			return nil, err
+		}
+		schema = engine.InputsSchema(*wf)
+	}
+	if schema == nil {
+		schema = &engine.Schema{Type: "object", Properties: map[string]*engine.Schema{}}
+	}
+	return json.Marshal(schema)
+}
+
+func (v *LLMVertex) loadTool(ctx *engine.Context, tool engine.ToolSpec) (*engine.Workflow, error) {
+	if v.Loader == nil {
+		return nil, fmt.Errorf("tool %s: no workflow store configured", tool.Name)
+	}
+	wf, version, err := v.Loader.LoadWorkflow(ctx.Ctx, tool.WorkflowID, tool.Version)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: failed to load workflow %s: %w", tool.Name, tool.WorkflowID, err)
+	}
+	if err := engine.Validate(*wf, v.Registry); err != nil {
+		return nil, fmt.Errorf("tool %s: workflow %s (version %d): %w", tool.Name, tool.WorkflowID, version, err)
+	}
+	return wf, nil
+}
+
+// runTool runs the node or workflow behind a tool call with the call's arguments as inputs
+func (v *LLMVertex) runTool(ctx *engine.Context, tools map[string]engine.ToolSpec, call llm.ToolCall) (interface{}, error) {
+	tool, ok := tools[call.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %q", call.Name)
+	}
+	args := map[string]interface{}{}
+	if strings.TrimSpace(call.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return nil, fmt.Errorf("arguments are not a JSON object: %w", err)
+		}
+	}
+
+	var wf *engine.Workflow
+	var err error
+	if tool.Node != "" {
+		wf, err = engine.ToolNodeWorkflow(ctx.Workflow, tool)
+	} else {
+		wf, err = v.loadTool(ctx, tool)
+	}
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := engine.ResolveInputs(*wf, args)
+	if err != nil {
+		return nil, err
+	}
+	child, err := ctx.RunSubWorkflow(*wf, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	if tool.Node == "" {
+		return outputsResult(child.Outputs), nil
+	}
+	result, _ := child.GetResult(tool.Node)
+	if m, ok := result.(map[string]interface{}); ok {
+		if r, ok := m["result"]; ok {
+			return r, nil
+		}
+	}
+	return result, nil
+}
+
+// toolResultText is the content of the message that returns a tool's result to the model
+func toolResultText(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// renderPrompt fills in a prompt template. A prompt with {{...}} expressions controls where
+// upstream results go; otherwise they are appended as context.
+func renderPrompt(ctx *engine.Context, messages []engine.Message, prompt string) (string, error) {
+	tmpl, err := engine.ParseTemplate(prompt)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	if len(tmpl.Refs()) > 0 {
+		rendered, err := tmpl.Render(ctx.TemplateScope(messages))
+		if err != nil {
+			return "", fmt.Errorf("failed to render prompt: %w", err)
+		}
+		return rendered, nil
+	}
+
+	var inputData string
+	for _, msg := range messages {
+		if val, ok := msg.Content["result"]; ok {
+			inputData += fmt.Sprintf("%v ", val)
+		}
+	}
+	if inputData != "" {
+		return fmt.Sprintf("%s\nContext: %s", prompt, inputData), nil
+	}
+	return prompt, nil
+}
+
+// renderSystemPrompt fills in data.system_prompt, which may reference upstream results like the prompt
+func renderSystemPrompt(ctx *engine.Context, messages []engine.Message, node *engine.Node) (string, error) {
+	system, _ := node.Data["system_prompt"].(string)
+	if system == "" {
+		return "", nil
+	}
+	tmpl, err := engine.ParseTemplate(system)
+	if err != nil {
+		return "", fmt.Errorf("invalid system prompt template: %w", err)
+	}
+	rendered, err := tmpl.Render(ctx.TemplateScope(messages))
+	if err != nil {
+		return "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+	return rendered, nil
+}
+
+// DefaultContextWindow is the context window in tokens of CHAT nodes that don't set data.context_window
+const DefaultContextWindow = 8000
+
+// TranscriptStore keeps the transcripts of CHAT nodes that set data.session, so a conversation
+// continues across runs
+type TranscriptStore interface {
+	LoadTranscript(ctx context.Context, session string) ([]llm.ChatMessage, error)
+	SaveTranscript(ctx context.Context, session string, transcript []llm.ChatMessage) error
+}
+
+// ChatVertex is an LLM node that keeps a conversation. Each computation adds the rendered prompt
+// as a user turn and the reply as an assistant turn to a transcript that lasts for the run, e.g.
+// across loop iterations, and across runs when data.session names a stored conversation. The
+// transcript is kept in full; the copy sent to the model is trimmed to data.context_window,
+// oldest turns first.
+type ChatVertex struct {
+	LLMVertex
+	Transcripts TranscriptStore
+}
+
+func (v *ChatVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	node := ctx.Node()
+	if node == nil {
+		return fmt.Errorf("chat node %s not found in workflow", ctx.NodeID)
+	}
+	fmt.Printf("[ChatVertex %s] Computing at step %d. Messages: %d\n", ctx.NodeID, ctx.Step, len(messages))
+
+	prompt, _ := node.Data["prompt"].(string)
+	turn, err := renderPrompt(ctx, messages, prompt)
+	if err != nil {
+		return err
+	}
+	system, err := renderSystemPrompt(ctx, messages, node)
+	if err != nil {
+		return err
+	}
+	session, err := chatSession(ctx, messages, node)
+	if err != nil {
+		return err
+	}
+
+	transcript, err := v.transcript(ctx, session)
+	if err != nil {
+		return err
+	}
+	transcript = append(transcript, llm.ChatMessage{Role: llm.RoleUser, Content: turn})
+
+	req := llmRequest(node.Data, "", system)
+	window := DefaultContextWindow
+	if n, ok := node.Data["context_window"].(float64); ok && n > 0 {
+		window = int(n)
+	}
+	// Leave room for the system prompt and the reply
+	reserve := req.MaxTokens
+	if reserve == 0 {
+		reserve = window / 4
+	}
+	req.Messages = llm.TrimMessages(transcript, window-reserve-llm.EstimateTokens(system))
+
+	client, err := v.client(node, ctx.Workflow)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[ChatVertex %s] Calling LLM with %d of %d messages\n", ctx.NodeID, len(req.Messages), len(transcript))
+	reply, err := client.Generate(ctx.Ctx, req)
+	if err != nil {
+		return fmt.Errorf("LLM generation failed: %w", err)
+	}
+
+	// The full transcript is kept; only the copy sent to the model is trimmed. Node state is
+	// updated last so that a retry after a failed save doesn't add the user turn twice.
+	transcript = append(transcript, llm.ChatMessage{Role: llm.RoleAssistant, Content: reply})
+	if session != "" && v.Transcripts != nil {
+		if err := v.Transcripts.SaveTranscript(ctx.Ctx, session, transcript); err != nil {
+			return fmt.Errorf("failed to save chat session: %w", err)
+		}
+	}
+	ctx.Execution.StoreNodeState(ctx.NodeID, transcriptState(transcript))
+
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{
+		"result":     reply,
+		"transcript": transcriptState(transcript),
+		"turns":      len(transcript),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+	if err := ctx.SendToChildren(map[string]interface{}{"result": reply}); err != nil {
+		return fmt.Errorf("routing failed: %w", err)
+	}
+	return nil
+}
+
+// chatSession renders data.session into the key of a stored conversation, scoped to the workflow
+func chatSession(ctx *engine.Context, messages []engine.Message, node *engine.Node) (string, error) {
+	session, _ := node.Data["session"].(string)
+	if session == "" {
+		return "", nil
+	}
+	tmpl, err := engine.ParseTemplate(session)
+	if err != nil {
+		return "", fmt.Errorf("invalid session template: %w", err)
+	}
+	if session, err = tmpl.Render(ctx.TemplateScope(messages)); err != nil {
+		return "", fmt.Errorf("failed to render session: %w", err)
+	}
+	if session == "" {
+		return "", nil
+	}
+	return ctx.Workflow.ID + ":" + session, nil
+}
+
+// transcript returns the conversation so far: the one of this run, or else the stored session
+func (v *ChatVertex) transcript(ctx *engine.Context, session string) ([]llm.ChatMessage, error) {
+	if state, ok := ctx.Execution.LoadNodeState(ctx.NodeID); ok {
+		return decodeTranscript(state), nil
+	}
+	if session == "" || v.Transcripts == nil {
+		return nil, nil
+	}
+	transcript, err := v.Transcripts.LoadTranscript(ctx.Ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat session: %w", err)
+	}
+	return transcript, nil
+}
+
+// transcriptState converts a transcript into plain values, the form node state and results keep
+// after a checkpoint round trip
+func transcriptState(transcript []llm.ChatMessage) []interface{} {
+	state := make([]interface{}, len(transcript))
+	for i, msg := range transcript {
+		state[i] = map[string]interface{}{"role": msg.Role, "content": msg.Content}
+	}
+	return state
+}
+
+func decodeTranscript(state interface{}) []llm.ChatMessage {
+	entries, _ := state.([]interface{})
+	transcript := make([]llm.ChatMessage, 0, len(entries))
+	for _, entry := range entries {
+		m, _ := entry.(map[string]interface{})
+		role, _ := m["role"].(string)
+		content, _ := m["content"].(string)
+		transcript = append(transcript, llm.ChatMessage{Role: role, Content: content})
+	}
+	return transcript
+}
+
+// StartVertex is the entry point of a workflow. It forwards what it receives to its children.
+type StartVertex struct{}
+
+func (v *StartVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	fmt.Printf("[StartVertex %s] Starting with %d messages\n", ctx.NodeID, len(messages))
+
+	for _, msg := range messages {
+		if err := ctx.SendToChildren(msg.Content); err != nil {
+			return fmt.Errorf("routing failed: %w", err)
 		}
+		ctx.Execution.SetResult(ctx.NodeID, msg.Content)
 	}
 
 	return nil
 }
 
-// ResultVertex displays/stores the result
+// ResultVertex collects the results sent to it across the whole run. Inputs are merged as a list
+// in arrival order (data.merge "list") or keyed by sender (data.merge "keyed", the default), and
+// rendered into "result" as plain text, JSON or markdown according to data.format.
 type ResultVertex struct{}
 
 func (v *ResultVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
 	fmt.Printf("[ResultVertex %s] Received results: %d\n", ctx.NodeID, len(messages))
 
+	merge, format := "keyed", "text"
+	if node := ctx.Node(); node != nil {
+		if m, ok := node.Data["merge"].(string); ok && m != "" {
+			merge = m
+		}
+		if f, ok := node.Data["format"].(string); ok && f != "" {
+			format = f
+		}
+	}
+
+	// Earlier supersteps' inputs are kept in node state so late branches add to them
+	var received []interface{}
+	if state, ok := ctx.Execution.LoadNodeState(ctx.NodeID); ok {
+		received, _ = state.([]interface{})
+	}
 	for _, msg := range messages {
 		fmt.Printf("  -> From %s: %v\n", msg.From, msg.Content)
-		// Store in ExecutionContext
-		ctx.Execution.Results[ctx.NodeID] = msg.Content
+		received = append(received, map[string]interface{}{"from": msg.From, "content": msg.Content})
+	}
+	ctx.Execution.StoreNodeState(ctx.NodeID, received)
+
+	entries := make([]resultEntry, 0, len(received))
+	for _, r := range received {
+		m, _ := r.(map[string]interface{})
+		from, _ := m["from"].(string)
+		content, _ := m["content"].(map[string]interface{})
+		entries = append(entries, resultEntry{from: from, content: content})
+	}
+
+	var inputs interface{}
+	switch merge {
+	case "list":
+		list := make([]interface{}, len(entries))
+		for i, e := range entries {
+			list[i] = e.value()
+		}
+		inputs = list
+	case "keyed":
+		keyed := make(map[string]interface{}, len(entries))
+		for _, e := range entries {
+			keyed[e.from] = e.value()
+		}
+		inputs = keyed
+	default:
+		return fmt.Errorf("unknown merge mode %q", merge)
+	}
+
+	rendered, err := renderResult(ctx, entries, inputs, format)
+	if err != nil {
+		return err
+	}
+
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{
+		"result": rendered,
+		"inputs": inputs,
+		"format": format,
+	})
+
+	return nil
+}
+
+// resultEntry is one message received by a ResultVertex
+type resultEntry struct {
+	from    string
+	content map[string]interface{}
+}
+
+// value is the part of the message worth keeping: its "result" if present, otherwise the whole content
+func (e resultEntry) value() interface{} {
+	if r, ok := e.content["result"]; ok {
+		return r
+	}
+	return e.content
+}
+
+func (e resultEntry) text() string {
+	if s, ok := e.value().(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(e.value())
+	return string(data)
+}
+
+func renderResult(ctx *engine.Context, entries []resultEntry, inputs interface{}, format string) (string, error) {
+	switch format {
+	case "text":
+		parts := make([]string, len(entries))
+		for i, e := range entries {
+			parts[i] = e.text()
+		}
+		return strings.Join(parts, "\n\n"), nil
+	case "json":
+		data, err := json.MarshalIndent(inputs, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to render JSON: %w", err)
+		}
+		return string(data), nil
+	case "markdown":
+		var b strings.Builder
+		for i, e := range entries {
+			if i > 0 {
+				b.WriteString("\n\n")
+			}
+			title := e.from
+			for _, node := range ctx.Workflow.Nodes {
+				if node.ID == e.from {
+					title = node.Label()
+					break
+				}
+			}
+			fmt.Fprintf(&b, "## %s\n\n%s", title, e.text())
+		}
+		return b.String(), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// EndVertex marks the completion of the workflow and collects the final outputs, keyed by the
+// node that sent them. With data.terminate set, the run stops after the current superstep even if
+// other branches are still active.
+type EndVertex struct{}
+
+func (v *EndVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	fmt.Printf("[EndVertex %s] Completing with %d messages\n", ctx.NodeID, len(messages))
+
+	outputs := map[string]interface{}{}
+	var last interface{}
+	if prev, ok := ctx.Execution.GetResult(ctx.NodeID); ok {
+		if m, ok := prev.(map[string]interface{}); ok {
+			if o, ok := m["outputs"].(map[string]interface{}); ok {
+				for k, val := range o {
+					outputs[k] = val
+				}
+			}
+			last = m["result"]
+		}
+	}
+	for _, msg := range messages {
+		last = resultEntry{from: msg.From, content: msg.Content}.value()
+		outputs[msg.From] = last
+	}
+
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{
+		"result":    last,
+		"outputs":   outputs,
+		"completed": true,
+	})
+
+	if node := ctx.Node(); node != nil {
+		if terminate, _ := node.Data["terminate"].(bool); terminate {
+			ctx.Complete()
+		}
+	}
+
+	return nil
+}
+
+// RouterVertex forwards each incoming message along the outgoing edges whose condition matches it.
+// With data.mode "first" (the default) only the first matching conditional edge is taken, like a
+// switch statement; with "all" every matching edge is taken.
+type RouterVertex struct{}
+
+func (v *RouterVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	fmt.Printf("[RouterVertex %s] Routing %d messages\n", ctx.NodeID, len(messages))
+
+	mode := "first"
+	if node := ctx.Node(); node != nil {
+		if m, ok := node.Data["mode"].(string); ok && m != "" {
+			mode = m
+		}
+	}
+
+	var lastContent map[string]interface{}
+	for _, msg := range messages {
+		var err error
+		switch mode {
+		case "first":
+			err = ctx.SendToFirstMatch(msg.Content)
+		case "all":
+			err = ctx.SendToChildren(msg.Content)
+		default:
+			return fmt.Errorf("unknown router mode %q", mode)
+		}
+		if err != nil {
+			return fmt.Errorf("routing failed: %w", err)
+		}
+		lastContent = msg.Content
 	}
 
+	routedTo := make([]string, 0, len(ctx.Outbox))
+	for _, msg := range ctx.Outbox {
+		routedTo = append(routedTo, msg.To)
+	}
+
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{
+		"result":    lastContent["result"],
+		"routed_to": routedTo,
+	})
+
 	return nil
 }
+
+// LoopVertex repeats its body until data.exit_condition matches the body's output or
+// data.max_iterations is reached. Edges leaving through the "exit" handle are taken when
+// the loop finishes; all other outgoing edges form the body, which should lead back here.
+type LoopVertex struct{}
+
+func (v *LoopVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	node := ctx.Node()
+	if node == nil {
+		return fmt.Errorf("loop node %s not found in workflow", ctx.NodeID)
+	}
+
+	maxIterations := engine.DefaultLoopIterations
+	if n, ok := node.Data["max_iterations"].(float64); ok && n > 0 {
+		maxIterations = int(n)
+	}
+	exitCondition, err := engine.DecodeCondition(node.Data["exit_condition"])
+	if err != nil {
+		return fmt.Errorf("loop %q: %w", node.Label(), err)
+	}
+
+	// Messages from outside and from the body are merged, with the body's values taking
+	// precedence since they carry the result of the latest iteration
+	body := engine.LoopBody(ctx.Workflow, ctx.NodeID)
+	content := map[string]interface{}{}
+	for _, fromBody := range []bool{false, true} {
+		for _, msg := range messages {
+			if body[msg.From] != fromBody {
+				continue
+			}
+			for k, val := range msg.Content {
+				content[k] = val
+			}
+		}
+	}
+
+	iteration := ctx.Execution.LoopIteration(ctx.NodeID)
+	fmt.Printf("[LoopVertex %s] Iteration %d/%d\n", ctx.NodeID, iteration, maxIterations)
+
+	exit := false
+	if iteration > 0 && exitCondition != nil {
+		// The exit condition can also test aggregator values, e.g. field "aggregates.best_score"
+		input := map[string]interface{}{"aggregates": ctx.Aggregates()}
+		for k, val := range content {
+			input[k] = val
+		}
+		if exit, err = exitCondition.Evaluate(input); err != nil {
+			return fmt.Errorf("loop %q: exit condition: %w", node.Label(), err)
+		}
+	}
+	if !exit && iteration >= maxIterations {
+		if exitCondition != nil {
+			return fmt.Errorf("loop %q (node %s) exceeded its limit of %d iterations without meeting its exit condition", node.Label(), ctx.NodeID, maxIterations)
+		}
+		// Without an exit condition the loop simply runs max_iterations times
+		exit = true
+	}
+
+	content["iteration"] = iteration
+	if exit {
+		ctx.Execution.SetLoopIteration(ctx.NodeID, 0)
+	} else {
+		iteration++
+		content["iteration"] = iteration
+		ctx.Execution.SetLoopIteration(ctx.NodeID, iteration)
+	}
+
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{
+		"result":    content["result"],
+		"iteration": iteration,
+		"exited":    exit,
+	})
+
+	for _, edge := range ctx.Workflow.Edges {
+		if edge.Source != ctx.NodeID || edge.SourceHandle == engine.ErrorHandle {
+			continue
+		}
+		if (edge.SourceHandle == engine.LoopExitHandle) == exit {
+			ctx.SendMessage(edge.Target, content)
+		}
+	}
+
+	return nil
+}
+
+// WorkflowLoader loads saved workflows for SUBWORKFLOW nodes
+type WorkflowLoader interface {
+	// LoadWorkflow returns the given version of a saved workflow, or its latest version if version is 0
+	LoadWorkflow(ctx context.Context, id string, version int) (*engine.Workflow, int, error)
+}
+
+// SubWorkflowVertex runs the saved workflow data.workflow_id (at data.version, if set) as a nested
+// execution. data.inputs maps each of its inputs to an expression over the parent's messages and
+// results, and its outputs are sent on to the children.
+type SubWorkflowVertex struct {
+	Loader   WorkflowLoader
+	Registry *engine.Registry
+}
+
+func (v *SubWorkflowVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	node := ctx.Node()
+	if node == nil {
+		return fmt.Errorf("node %s is not part of the workflow", ctx.NodeID)
+	}
+	if v.Loader == nil {
+		return fmt.Errorf("sub-workflows are not available: no workflow store configured")
+	}
+
+	id, _ := node.Data["workflow_id"].(string)
+	version := 0
+	if f, ok := node.Data["version"].(float64); ok {
+		version = int(f)
+	}
+	fmt.Printf("[SubWorkflowVertex %s] Running workflow %s (version %d)\n", ctx.NodeID, id, version)
+
+	wf, version, err := v.Loader.LoadWorkflow(ctx.Ctx, id, version)
+	if err != nil {
+		return fmt.Errorf("failed to load sub-workflow %s: %w", id, err)
+	}
+	if err := engine.Validate(*wf, v.Registry); err != nil {
+		return fmt.Errorf("sub-workflow %s (version %d): %w", id, version, err)
+	}
+
+	scope := ctx.TemplateScope(messages)
+	provided := map[string]interface{}{}
+	mapping, _ := node.Data["inputs"].(map[string]interface{})
+	for name, expr := range mapping {
+		value, err := engine.EvalExpression(fmt.Sprint(expr), scope)
+		if err != nil {
+			return fmt.Errorf("input %s: %w", name, err)
+		}
+		provided[name] = value
+	}
+	inputs, err := engine.ResolveInputs(*wf, provided)
+	if err != nil {
+		return fmt.Errorf("sub-workflow %s: %w", id, err)
+	}
+
+	child, err := ctx.RunSubWorkflow(*wf, inputs)
+	if err != nil {
+		return fmt.Errorf("sub-workflow %s failed: %w", id, err)
+	}
+
+	result := outputsResult(child.Outputs)
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{
+		"result":      result,
+		"outputs":     child.Outputs,
+		"workflow_id": id,
+		"version":     version,
+	})
+
+	return ctx.SendToChildren(map[string]interface{}{"result": result, "outputs": child.Outputs})
+}
+
+// outputsResult is what a nested run passes on as its "result": its "result" output if it
+// declares one, otherwise all of its outputs
+func outputsResult(outputs map[string]interface{}) interface{} {
+	if r, ok := outputs["result"]; ok {
+		return r
+	}
+	return outputs
+}
+
+// MapVertex runs an inline node (data.node) or a saved workflow (data.workflow_id) once for each
+// item of a list, at most data.concurrency at a time, and sends the results on in item order.
+// The list is taken from data.items, an expression, or else from the incoming "result".
+type MapVertex struct {
+	Loader   WorkflowLoader
+	Registry *engine.Registry
+}
+
+func (v *MapVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	node := ctx.Node()
+	if node == nil {
+		return fmt.Errorf("node %s is not part of the workflow", ctx.NodeID)
+	}
+
+	items, err := mapItems(ctx, node, messages)
+	if err != nil {
+		return err
+	}
+	run, err := v.itemRunner(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	concurrency := engine.DefaultMapConcurrency
+	if f, ok := node.Data["concurrency"].(float64); ok && f >= 1 {
+		concurrency = int(f)
+	}
+	fmt.Printf("[MapVertex %s] Processing %d items, %d at a time\n", ctx.NodeID, len(items), concurrency)
+
+	results := make([]interface{}, len(items))
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	// Stop starting items once the run is cancelled
+fanOut:
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Ctx.Done():
+			break fanOut
+		}
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = run(i, item)
+		}(i, item)
+	}
+	wg.Wait()
+	if err := ctx.Ctx.Err(); err != nil {
+		return err
+	}
+
+	policy, _ := node.Data["on_item_error"].(string)
+	out := make([]interface{}, 0, len(items))
+	itemErrors := []interface{}{}
+	for i, err := range errs {
+		if err == nil {
+			out = append(out, results[i])
+			continue
+		}
+		if policy == "" || policy == engine.MapFailAll {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		itemErrors = append(itemErrors, map[string]interface{}{"index": i, "error": err.Error()})
+		if policy == engine.MapKeepNull {
+			out = append(out, nil)
+		}
+	}
+
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{
+		"result": out,
+		"count":  len(items),
+		"errors": itemErrors,
+	})
+
+	return ctx.SendToChildren(map[string]interface{}{"result": out})
+}
+
+// itemRunner returns the function that processes a single item
+func (v *MapVertex) itemRunner(ctx *engine.Context, node *engine.Node) (func(index int, item interface{}) (interface{}, error), error) {
+	if id, _ := node.Data["workflow_id"].(string); id != "" {
+		if v.Loader == nil {
+			return nil, fmt.Errorf("sub-workflows are not available: no workflow store configured")
+		}
+		version := 0
+		if f, ok := node.Data["version"].(float64); ok {
+			version = int(f)
+		}
+		wf, version, err := v.Loader.LoadWorkflow(ctx.Ctx, id, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load workflow %s: %w", id, err)
+		}
+		if err := engine.Validate(*wf, v.Registry); err != nil {
+			return nil, fmt.Errorf("workflow %s (version %d): %w", id, version, err)
+		}
+		inputName := engine.MapItemInput
+		if name, ok := node.Data["item_input"].(string); ok && name != "" {
+			inputName = name
+		}
+
+		return func(index int, item interface{}) (interface{}, error) {
+			inputs, err := engine.ResolveInputs(*wf, map[string]interface{}{inputName: item})
+			if err != nil {
+				return nil, err
+			}
+			child, err := ctx.RunSubWorkflow(*wf, inputs)
+			if err != nil {
+				return nil, err
+			}
+			return outputsResult(child.Outputs), nil
+		}, nil
+	}
+
+	inner, err := engine.MapInnerWorkflow(ctx.Workflow, node)
+	if err != nil {
+		return nil, err
+	}
+	if err := engine.Validate(*inner, v.Registry); err != nil {
+		return nil, err
+	}
+	innerID := inner.Nodes[0].ID
+
+	return func(index int, item interface{}) (interface{}, error) {
+		child, err := ctx.RunSubWorkflow(*inner, map[string]interface{}{
+			engine.MapItemInput:  item,
+			engine.MapIndexInput: index,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result, _ := child.GetResult(innerID)
+		if m, ok := result.(map[string]interface{}); ok {
+			if r, ok := m["result"]; ok {
+				return r, nil
+			}
+		}
+		return result, nil
+	}, nil
+}
+
+// mapItems finds the list a MAP node iterates over. A string holding a JSON array is decoded,
+// since that is what LLM nodes usually produce.
+func mapItems(ctx *engine.Context, node *engine.Node, messages []engine.Message) ([]interface{}, error) {
+	var value interface{}
+	if expr, ok := node.Data["items"].(string); ok && expr != "" {
+		v, err := engine.EvalExpression(expr, ctx.TemplateScope(messages))
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		value = v
+	} else {
+		for _, msg := range messages {
+			if r, ok := msg.Content["result"]; ok {
+				value = r
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case string:
+		var items []interface{}
+		if err := json.Unmarshal([]byte(v), &items); err == nil {
+			return items, nil
+		}
+	}
+	return nil, fmt.Errorf("expected a list of items, got %T", value)
+}
+
+// JoinVertex merges what its parents delivered once its wait-for policy (data.wait_for, "all" by
+// default) is satisfied. The result lists the parents' results in the order they arrived.
+type JoinVertex struct{}
+
+func (v *JoinVertex) Compute(ctx *engine.Context, messages []engine.Message) error {
+	fmt.Printf("[JoinVertex %s] Joining %d messages\n", ctx.NodeID, len(messages))
+
+	results := make([]interface{}, 0, len(messages))
+	inputs := make(map[string]interface{}, len(messages))
+	for _, msg := range messages {
+		value := resultEntry{from: msg.From, content: msg.Content}.value()
+		results = append(results, value)
+		inputs[msg.From] = value
+	}
+
+	ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{
+		"result": results,
+		"inputs": inputs,
+	})
+
+	return ctx.SendToChildren(map[string]interface{}{"result": results, "inputs": inputs})
+}
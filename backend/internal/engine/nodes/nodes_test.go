@@ -0,0 +1,67 @@
+package nodes
+
+import (
+	"context"
+	"sync"
+
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/llm"
+)
+
+// scriptedLLM answers every request with reply and records what it was asked
+type scriptedLLM struct {
+	mu       sync.Mutex
+	reply    func(req llm.Request) (string, error)
+	requests []llm.Request
+}
+
+func (c *scriptedLLM) Generate(ctx context.Context, req llm.Request) (string, error) {
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.mu.Unlock()
+	if c.reply == nil {
+		return "ok", nil
+	}
+	return c.reply(req)
+}
+
+func (c *scriptedLLM) prompts() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prompts := make([]string, len(c.requests))
+	for i, req := range c.requests {
+		prompts[i] = req.Prompt
+	}
+	return prompts
+}
+
+// runWorkflow executes wf with the built-in node types
+func runWorkflow(wf engine.Workflow, inputs map[string]interface{}, deps Dependencies) (*engine.ExecutionContext, error) {
+	if deps.LLM == nil {
+		deps.LLM = &scriptedLLM{}
+	}
+	execCtx := engine.NewExecutionContext(wf.ID)
+	if inputs != nil {
+		execCtx.Inputs = inputs
+	}
+	err := engine.ExecuteBSP(context.Background(), wf, execCtx, NewRegistry(deps).Factory(), engine.BSPOptions{})
+	return execCtx, err
+}
+
+func node(id string, nodeType engine.NodeType, data map[string]interface{}) engine.Node {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return engine.Node{ID: id, Type: nodeType, Data: data}
+}
+
+func edge(source, target string) engine.Edge {
+	return engine.Edge{ID: source + "-" + target, Source: source, Target: target}
+}
+
+// conditional returns an edge taken when the condition matches
+func conditional(source, target string, cond engine.Condition) engine.Edge {
+	e := edge(source, target)
+	e.Condition = &cond
+	return e
+}
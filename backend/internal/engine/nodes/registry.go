@@ -0,0 +1,359 @@
+package nodes
+
+import (
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/llm"
+)
+
+// Dependencies are the services built-in vertices need at runtime
+type Dependencies struct {
+	LLM llm.Client
+	// Providers creates the clients of LLM nodes that select their own provider
+	Providers *llm.Registry
+	// Workflows loads the workflows SUBWORKFLOW nodes run; without it they fail
+	Workflows WorkflowLoader
+	// Transcripts keeps CHAT conversations across runs; without it data.session is ignored
+	Transcripts TranscriptStore
+}
+
+// NewRegistry returns a registry with all built-in node types
+func NewRegistry(deps Dependencies) *engine.Registry {
+	r := engine.NewRegistry()
+
+	llmSchema := &engine.Schema{
+		Type: "object",
+		Properties: map[string]*engine.Schema{
+			"label":  {Type: "string", Title: "Label"},
+			"prompt": {Type: "string", Title: "Prompt", MinLength: intPtr(1)},
+			"model": {
+				Type:        "string",
+				Title:       "Model",
+				Description: "Defaults to the server's model (LLM_MODEL) for the server's provider.",
+			},
+			"system_prompt": {Type: "string", Title: "System prompt"},
+			"temperature":   {Type: "number", Title: "Temperature", Minimum: floatPtr(0), Maximum: floatPtr(2)},
+			"top_p":         {Type: "number", Title: "Top P", Minimum: floatPtr(0), Maximum: floatPtr(1)},
+			"max_tokens": {
+				Type:        "integer",
+				Title:       "Max tokens",
+				Description: "Defaults to the server's limit (LLM_MAX_TOKENS).",
+				Minimum:     floatPtr(1),
+			},
+			"stop": {
+				Title: "Stop sequences",
+				AnyOf: []*engine.Schema{
+					{Type: "string"},
+					{Type: "array", Items: &engine.Schema{Type: "string"}},
+				},
+			},
+			"seed": {Type: "integer", Title: "Seed"},
+			"output_schema": {
+				Type:        "object",
+				Title:       "Output schema",
+				Description: "JSON Schema of a structured reply. The parsed value becomes the node's result.",
+			},
+			"tools": {
+				Type:        "array",
+				Title:       "Tools",
+				Description: "Tools the model may call, each run by a node of this workflow (node) or a saved workflow (workflow_id).",
+				Items: &engine.Schema{
+					Type: "object",
+					Properties: map[string]*engine.Schema{
+						"name":        {Type: "string", Title: "Name", MinLength: intPtr(1)},
+						"description": {Type: "string", Title: "Description"},
+						"node":        {Type: "string", Title: "Node"},
+						"workflow_id": {Type: "string", Title: "Workflow"},
+						"version":     {Type: "integer", Title: "Version", Minimum: floatPtr(1)},
+						"parameters":  {Type: "object", Title: "Parameters", Description: "JSON Schema of the arguments."},
+					},
+					Required: []string{"name"},
+				},
+			},
+			"max_tool_iterations": {
+				Type:        "integer",
+				Title:       "Max tool iterations",
+				Description: "How many replies the model may spend calling tools before the node fails.",
+				Minimum:     floatPtr(1),
+				Default:     engine.DefaultToolIterations,
+			},
+			"repair_attempts": {
+				Type:        "integer",
+				Title:       "Repair attempts",
+				Description: "How often an invalid structured reply is sent back to the model with its errors.",
+				Minimum:     floatPtr(0),
+				Default:     DefaultRepairAttempts,
+			},
+			"provider": {
+				Type:        "string",
+				Title:       "Provider",
+				Description: "LLM provider, e.g. openai, anthropic, gemini, ollama or openai_compatible. Defaults to the server's provider.",
+			},
+			"base_url": {
+				Type:        "string",
+				Title:       "Base URL",
+				Description: "Endpoint of the provider, required for openai_compatible.",
+			},
+		},
+		Required: []string{"prompt"},
+	}
+	newLLM := func(node engine.Node) (engine.Vertex, error) {
+		return &LLMVertex{Client: deps.LLM, Providers: deps.Providers, Loader: deps.Workflows, Registry: r}, nil
+	}
+
+	chatSchema := &engine.Schema{Type: "object", Properties: map[string]*engine.Schema{}, Required: llmSchema.Required}
+	for name, prop := range llmSchema.Properties {
+		chatSchema.Properties[name] = prop
+	}
+	// Structured output and tools are specific to the LLM node
+	for _, name := range []string{"output_schema", "repair_attempts", "tools", "max_tool_iterations"} {
+		delete(chatSchema.Properties, name)
+	}
+	chatSchema.Properties["context_window"] = &engine.Schema{
+		Type:        "integer",
+		Title:       "Context window",
+		Description: "Tokens of conversation sent to the model; older turns are dropped first.",
+		Minimum:     floatPtr(1),
+		Default:     DefaultContextWindow,
+	}
+	chatSchema.Properties["session"] = &engine.Schema{
+		Type:        "string",
+		Title:       "Session",
+		Description: "Key of a conversation to continue across runs, e.g. \"{{inputs.user_id}}\".",
+	}
+
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeStart,
+		DisplayName: "Start",
+		Description: "Entry point of the workflow. Forwards the trigger to its children.",
+		Schema: &engine.Schema{
+			Type:       "object",
+			Properties: map[string]*engine.Schema{"label": {Type: "string", Title: "Label"}},
+		},
+		New: func(node engine.Node) (engine.Vertex, error) { return &StartVertex{}, nil },
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeLLM,
+		DisplayName: "LLM",
+		Description: "Sends its prompt, followed by the results of its parents, to the language model, which may call tools.",
+		Schema:      llmSchema,
+		New:         newLLM,
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeTask,
+		DisplayName: "Task (LLM)",
+		Description: "Legacy alias of the LLM node.",
+		Schema:      llmSchema,
+		New:         newLLM,
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeChat,
+		DisplayName: "Chat",
+		Description: "Sends its prompt as the next user turn of a conversation that lasts across loop iterations and, with a session, across runs.",
+		Schema:      chatSchema,
+		New: func(node engine.Node) (engine.Vertex, error) {
+			return &ChatVertex{LLMVertex: LLMVertex{Client: deps.LLM, Providers: deps.Providers}, Transcripts: deps.Transcripts}, nil
+		},
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeResult,
+		DisplayName: "Result",
+		Description: "Merges the results sent to it into a list or an object keyed by sender.",
+		Schema: &engine.Schema{
+			Type: "object",
+			Properties: map[string]*engine.Schema{
+				"label": {Type: "string", Title: "Label"},
+				"merge": {
+					Type:        "string",
+					Title:       "Merge",
+					Description: "\"keyed\" keys inputs by the node that sent them, \"list\" keeps them in arrival order.",
+					Enum:        []interface{}{"keyed", "list"},
+					Default:     "keyed",
+				},
+				"format": {
+					Type:    "string",
+					Title:   "Output format",
+					Enum:    []interface{}{"text", "json", "markdown"},
+					Default: "text",
+				},
+			},
+		},
+		New: func(node engine.Node) (engine.Vertex, error) { return &ResultVertex{}, nil },
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeEnd,
+		DisplayName: "End",
+		Description: "Marks the workflow as complete and collects its final outputs.",
+		Schema: &engine.Schema{
+			Type: "object",
+			Properties: map[string]*engine.Schema{
+				"label": {Type: "string", Title: "Label"},
+				"terminate": {
+					Type:        "boolean",
+					Title:       "Terminate",
+					Description: "Stop the run as soon as this node completes, skipping branches still in progress.",
+					Default:     false,
+				},
+			},
+		},
+		New: func(node engine.Node) (engine.Vertex, error) { return &EndVertex{}, nil },
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeRouter,
+		DisplayName: "Router",
+		Description: "Forwards each message along the outgoing edges whose condition matches it.",
+		Schema: &engine.Schema{
+			Type: "object",
+			Properties: map[string]*engine.Schema{
+				"label": {Type: "string", Title: "Label"},
+				"mode": {
+					Type:        "string",
+					Title:       "Mode",
+					Description: "\"first\" takes only the first matching edge, \"all\" takes every matching edge.",
+					Enum:        []interface{}{"first", "all"},
+					Default:     "first",
+				},
+			},
+		},
+		New: func(node engine.Node) (engine.Vertex, error) { return &RouterVertex{}, nil },
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeLoop,
+		DisplayName: "Loop",
+		Description: "Repeats its body until the exit condition matches or max_iterations is reached.",
+		Schema: &engine.Schema{
+			Type: "object",
+			Properties: map[string]*engine.Schema{
+				"label": {Type: "string", Title: "Label"},
+				"max_iterations": {
+					Type:    "integer",
+					Title:   "Max iterations",
+					Minimum: floatPtr(1),
+					Default: engine.DefaultLoopIterations,
+				},
+				"exit_condition": {
+					Type:        "object",
+					Title:       "Exit condition",
+					Description: "Condition evaluated against the body's output.",
+					Properties: map[string]*engine.Schema{
+						"field":    {Type: "string"},
+						"operator": {Type: "string"},
+					},
+					Required: []string{"operator"},
+				},
+			},
+		},
+		New: func(node engine.Node) (engine.Vertex, error) { return &LoopVertex{}, nil },
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeSubWorkflow,
+		DisplayName: "Sub-workflow",
+		Description: "Runs a saved workflow with inputs mapped from this workflow and returns its outputs.",
+		Schema: &engine.Schema{
+			Type: "object",
+			Properties: map[string]*engine.Schema{
+				"label":       {Type: "string", Title: "Label"},
+				"workflow_id": {Type: "string", Title: "Workflow", MinLength: intPtr(1)},
+				"version": {
+					Type:        "integer",
+					Title:       "Version",
+					Description: "Saved version to run. The latest version is used if not set.",
+					Minimum:     floatPtr(1),
+				},
+				"inputs": {
+					Type:        "object",
+					Title:       "Inputs",
+					Description: "Maps each input of the sub-workflow to an expression, e.g. \"nodes.summarize.result\".",
+				},
+			},
+			Required: []string{"workflow_id"},
+		},
+		New: func(node engine.Node) (engine.Vertex, error) {
+			return &SubWorkflowVertex{Loader: deps.Workflows, Registry: r}, nil
+		},
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeJoin,
+		DisplayName: "Join",
+		Description: "Waits for its parents according to its wait-for policy and merges their results.",
+		Schema: &engine.Schema{
+			Type: "object",
+			Properties: map[string]*engine.Schema{
+				"label": {Type: "string", Title: "Label"},
+				"wait_for": {
+					Type:        "string",
+					Title:       "Wait for",
+					Description: "\"all\" parents, \"any\" one of them, or a \"quorum\" of them.",
+					Enum:        []interface{}{engine.WaitAll, engine.WaitAny, engine.WaitQuorum},
+					Default:     engine.WaitAll,
+				},
+				"quorum": {
+					Type:        "integer",
+					Title:       "Quorum",
+					Description: "Number of parents to wait for with wait_for \"quorum\".",
+					Minimum:     floatPtr(1),
+				},
+			},
+		},
+		New: func(node engine.Node) (engine.Vertex, error) { return &JoinVertex{}, nil },
+	})
+	r.MustRegister(engine.NodeSpec{
+		Type:        engine.NodeTypeMap,
+		DisplayName: "Map",
+		Description: "Runs an inner node or a saved workflow once per item of a list and collects the results in order.",
+		Schema: &engine.Schema{
+			Type: "object",
+			Properties: map[string]*engine.Schema{
+				"label": {Type: "string", Title: "Label"},
+				"items": {
+					Type:        "string",
+					Title:       "Items",
+					Description: "Expression selecting the list, e.g. \"nodes.split.result\". Defaults to the incoming result.",
+				},
+				"node": {
+					Type:        "object",
+					Title:       "Inner node",
+					Description: "Node run for each item; its templates can use {{inputs.item}} and {{inputs.index}}.",
+					Properties: map[string]*engine.Schema{
+						"type": {Type: "string"},
+						"data": {Type: "object"},
+					},
+					Required: []string{"type"},
+				},
+				"workflow_id": {Type: "string", Title: "Workflow", MinLength: intPtr(1)},
+				"version":     {Type: "integer", Title: "Version", Minimum: floatPtr(1)},
+				"item_input": {
+					Type:        "string",
+					Title:       "Item input",
+					Description: "Input of the workflow that receives the item.",
+					Default:     engine.MapItemInput,
+				},
+				"concurrency": {
+					Type:    "integer",
+					Title:   "Concurrency",
+					Minimum: floatPtr(1),
+					Default: engine.DefaultMapConcurrency,
+				},
+				"on_item_error": {
+					Type:        "string",
+					Title:       "On item error",
+					Description: "\"fail\" fails the node, \"skip\" drops failed items, \"null\" keeps nil in their place.",
+					Enum:        []interface{}{engine.MapFailAll, engine.MapSkipItem, engine.MapKeepNull},
+					Default:     engine.MapFailAll,
+				},
+			},
+		},
+		New: func(node engine.Node) (engine.Vertex, error) {
+			return &MapVertex{Loader: deps.Workflows, Registry: r}, nil
+		},
+	})
+
+	return r
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
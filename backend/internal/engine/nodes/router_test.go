@@ -0,0 +1,68 @@
+package nodes
+
+import (
+	"testing"
+
+	"workflow-platform/internal/engine"
+)
+
+// ticketWorkflow routes the ticket input to "urgent" or "refund" when it mentions them,
+// and to "other" otherwise
+func ticketWorkflow(mode string) engine.Workflow {
+	return engine.Workflow{
+		ID: "tickets",
+		Nodes: []engine.Node{
+			node("start", engine.NodeTypeStart, nil),
+			node("router", engine.NodeTypeRouter, map[string]interface{}{"mode": mode}),
+			node("urgent", engine.NodeTypeEnd, nil),
+			node("refund", engine.NodeTypeEnd, nil),
+			node("other", engine.NodeTypeEnd, nil),
+		},
+		Edges: []engine.Edge{
+			edge("start", "router"),
+			conditional("router", "urgent", engine.Condition{Field: "inputs.ticket", Operator: engine.OpContains, Value: "urgent", IgnoreCase: true}),
+			conditional("router", "refund", engine.Condition{Field: "inputs.ticket", Operator: engine.OpContains, Value: "refund"}),
+			conditional("router", "other", engine.Condition{Operator: engine.OpElse}),
+		},
+	}
+}
+
+func TestRouterTakesBranches(t *testing.T) {
+	tests := []struct {
+		mode, ticket string
+		want         []string
+	}{
+		{"first", "URGENT: refund my order", []string{"urgent"}},
+		{"all", "URGENT: refund my order", []string{"urgent", "refund"}},
+		{"first", "please refund my order", []string{"refund"}},
+		{"first", "how do I log in?", []string{"other"}},
+	}
+	for _, tt := range tests {
+		execCtx, err := runWorkflow(ticketWorkflow(tt.mode), map[string]interface{}{"ticket": tt.ticket}, Dependencies{})
+		if err != nil {
+			t.Fatalf("%s %q: %v", tt.mode, tt.ticket, err)
+		}
+
+		taken := map[string]bool{}
+		for _, id := range tt.want {
+			taken[id] = true
+		}
+		for _, id := range []string{"urgent", "refund", "other"} {
+			_, ran := execCtx.GetResult(id)
+			if ran != taken[id] {
+				t.Errorf("%s %q: expected branch %s taken=%v", tt.mode, tt.ticket, id, taken[id])
+			}
+		}
+		result, _ := execCtx.GetResult("router")
+		if routed := result.(map[string]interface{})["routed_to"].([]string); len(routed) != len(tt.want) {
+			t.Errorf("%s %q: expected routed_to %v, got %v", tt.mode, tt.ticket, tt.want, routed)
+		}
+	}
+}
+
+func TestRouterRejectsUnknownMode(t *testing.T) {
+	_, err := runWorkflow(ticketWorkflow("random"), map[string]interface{}{"ticket": "hi"}, Dependencies{})
+	if err == nil {
+		t.Fatal("expected an unknown router mode to fail the run")
+	}
+}
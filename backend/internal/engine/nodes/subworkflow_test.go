@@ -0,0 +1,93 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/llm"
+)
+
+// mapLoader serves saved workflows from memory, all at version 1
+type mapLoader map[string]*engine.Workflow
+
+func (l mapLoader) LoadWorkflow(ctx context.Context, id string, version int) (*engine.Workflow, int, error) {
+	wf, ok := l[id]
+	if !ok {
+		return nil, 0, fmt.Errorf("workflow %s not found", id)
+	}
+	return wf, 1, nil
+}
+
+// echoLLM replies with the prompt it was given
+func echoLLM() *scriptedLLM {
+	return &scriptedLLM{reply: func(req llm.Request) (string, error) { return "re: " + req.Prompt, nil }}
+}
+
+// summarizer is a saved workflow summarizing its text input
+var summarizer = &engine.Workflow{
+	ID:      "summarizer",
+	Inputs:  []engine.InputParam{{Name: "text", Type: "string", Required: true}},
+	Outputs: []engine.OutputParam{{Name: "result", Source: "nodes.sum.result"}},
+	Nodes: []engine.Node{
+		node("start", engine.NodeTypeStart, nil),
+		node("sum", engine.NodeTypeLLM, map[string]interface{}{"prompt": "Summarize {{inputs.text}}"}),
+	},
+	Edges: []engine.Edge{edge("start", "sum")},
+}
+
+// callSummarizer writes about the topic input and summarizes the text in a sub-workflow
+func callSummarizer(inputs map[string]interface{}) engine.Workflow {
+	return engine.Workflow{
+		ID: "writer",
+		Nodes: []engine.Node{
+			node("start", engine.NodeTypeStart, nil),
+			node("write", engine.NodeTypeLLM, map[string]interface{}{"prompt": "Write about {{inputs.topic}}"}),
+			node("sub", engine.NodeTypeSubWorkflow, map[string]interface{}{"workflow_id": "summarizer", "inputs": inputs}),
+			node("end", engine.NodeTypeEnd, nil),
+		},
+		Edges: []engine.Edge{edge("start", "write"), edge("write", "sub"), edge("sub", "end")},
+	}
+}
+
+func TestSubWorkflowMapsInputsAndOutputs(t *testing.T) {
+	wf := callSummarizer(map[string]interface{}{"text": "nodes.write.result | upper"})
+	deps := Dependencies{LLM: echoLLM(), Workflows: mapLoader{"summarizer": summarizer}}
+
+	execCtx, err := runWorkflow(wf, map[string]interface{}{"topic": "go"}, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "re: Summarize RE: WRITE ABOUT GO"
+	sub, _ := execCtx.GetResult("sub")
+	if m := sub.(map[string]interface{}); m["result"] != want || m["version"] != 1 {
+		t.Errorf("expected the summarizer's result %q at version 1, got %v", want, m)
+	}
+	end, _ := execCtx.GetResult("end")
+	if got := end.(map[string]interface{})["result"]; got != want {
+		t.Errorf("expected the sub-workflow's result to be sent on, got %v", got)
+	}
+}
+
+func TestSubWorkflowFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs map[string]interface{}
+		deps   Dependencies
+		want   string
+	}{
+		{"no store", nil, Dependencies{}, "no workflow store configured"},
+		{"unknown workflow", nil, Dependencies{Workflows: mapLoader{}}, "workflow summarizer not found"},
+		{"missing input", nil, Dependencies{Workflows: mapLoader{"summarizer": summarizer}}, `missing required input "text"`},
+		{"bad expression", map[string]interface{}{"text": "nodes.nowhere.result"}, Dependencies{Workflows: mapLoader{"summarizer": summarizer}}, "input text"},
+	}
+	for _, tt := range tests {
+		_, err := runWorkflow(callSummarizer(tt.inputs), map[string]interface{}{"topic": "go"}, tt.deps)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
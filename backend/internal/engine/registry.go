@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NodeSpec describes a node type that workflows can use
+type NodeSpec struct {
+	Type        NodeType `json:"type"`
+	DisplayName string   `json:"display_name"`
+	Description string   `json:"description,omitempty"`
+	// Schema describes the node's Data fields
+	Schema *Schema `json:"schema"`
+	// Combiner names the combiner applied to messages for nodes of this type that don't set
+	// data.combiner. Empty means messages are delivered as sent.
+	Combiner string `json:"combiner,omitempty"`
+	// New creates the vertex that executes a node of this type
+	New func(node Node) (Vertex, error) `json:"-"`
+}
+
+// Registry holds the node types available to the engine
+type Registry struct {
+	mu    sync.RWMutex
+	specs map[NodeType]NodeSpec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{specs: make(map[NodeType]NodeSpec)}
+}
+
+// Register adds a node type. Registering the same type twice is an error.
+func (r *Registry) Register(spec NodeSpec) error {
+	if spec.Type == "" || spec.New == nil {
+		return fmt.Errorf("node spec needs a type and a constructor")
+	}
+	if _, ok := lookupCombiner(spec.Combiner); spec.Combiner != "" && !ok {
+		return fmt.Errorf("node type %s uses unknown combiner %q", spec.Type, spec.Combiner)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.specs[spec.Type]; exists {
+		return fmt.Errorf("node type %s is already registered", spec.Type)
+	}
+	r.specs[spec.Type] = spec
+	return nil
+}
+
+// MustRegister is like Register but panics on error. It is meant for built-in node types.
+func (r *Registry) MustRegister(spec NodeSpec) {
+	if err := r.Register(spec); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the spec of a node type
+func (r *Registry) Lookup(nodeType NodeType) (NodeSpec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	spec, ok := r.specs[nodeType]
+	return spec, ok
+}
+
+// Specs returns all registered node types sorted by type
+func (r *Registry) Specs() []NodeSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	specs := make([]NodeSpec, 0, len(r.specs))
+	for _, spec := range r.specs {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Type < specs[j].Type })
+	return specs
+}
+
+// Factory returns a VertexFactory backed by the registry
+func (r *Registry) Factory() VertexFactory {
+	return func(node Node) (Vertex, error) {
+		spec, ok := r.Lookup(node.Type)
+		if !ok {
+			return nil, fmt.Errorf("unknown node type: %s", node.Type)
+		}
+		v, err := spec.New(node)
+		if err != nil || spec.Combiner == "" {
+			return v, err
+		}
+		c, _ := lookupCombiner(spec.Combiner)
+		return &combinedVertex{Vertex: v, combiner: c}, nil
+	}
+}
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// Error classes a retry policy can select
+const (
+	ErrorClassRateLimit = "rate_limit"   // HTTP 429
+	ErrorClassServer    = "server_error" // HTTP 5xx
+	ErrorClassTimeout   = "timeout"      // deadlines and HTTP 408
+	ErrorClassNetwork   = "network"      // no response received
+	ErrorClassOther     = "other"
+	ErrorClassAll       = "all" // matches every class
+)
+
+// Retry defaults for fields a policy leaves unset
+const (
+	DefaultRetryBackoff    = 500 * time.Millisecond
+	DefaultRetryMaxBackoff = 30 * time.Second
+	DefaultRetryMultiplier = 2.0
+)
+
+// transientErrorClasses are retried when a policy doesn't list its own classes
+var transientErrorClasses = []string{ErrorClassRateLimit, ErrorClassServer, ErrorClassTimeout, ErrorClassNetwork}
+
+// RetryPolicy controls how often a failing node is re-run within a superstep.
+// Nodes set it in data.retry; unset fields fall back to the run's default policy.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of Compute calls, including the first one
+	MaxAttempts  int      `json:"max_attempts,omitempty"`
+	BackoffMS    int      `json:"backoff_ms,omitempty"`
+	MaxBackoffMS int      `json:"max_backoff_ms,omitempty"`
+	Multiplier   float64  `json:"multiplier,omitempty"`
+	RetryOn      []string `json:"retry_on,omitempty"`
+}
+
+// DecodeRetryPolicy converts the retry settings of node data into a RetryPolicy
+func DecodeRetryPolicy(v interface{}) (*RetryPolicy, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var p RetryPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid retry policy: %w", err)
+	}
+	return &p, nil
+}
+
+// withDefaults fills the fields p leaves unset from def
+func (p RetryPolicy) withDefaults(def RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.BackoffMS == 0 {
+		p.BackoffMS = def.BackoffMS
+	}
+	if p.MaxBackoffMS == 0 {
+		p.MaxBackoffMS = def.MaxBackoffMS
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = def.Multiplier
+	}
+	if len(p.RetryOn) == 0 {
+		p.RetryOn = def.RetryOn
+	}
+	return p
+}
+
+// Retries reports whether an error of this class should be retried under the policy
+func (p RetryPolicy) Retries(err error) bool {
+	classes := p.RetryOn
+	if len(classes) == 0 {
+		classes = transientErrorClasses
+	}
+	class := ErrorClass(err)
+	for _, c := range classes {
+		if c == ErrorClassAll || c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay before the attempt following the given one (1-based)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	base := DefaultRetryBackoff
+	if p.BackoffMS > 0 {
+		base = time.Duration(p.BackoffMS) * time.Millisecond
+	}
+	maxDelay := DefaultRetryMaxBackoff
+	if p.MaxBackoffMS > 0 {
+		maxDelay = time.Duration(p.MaxBackoffMS) * time.Millisecond
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+
+	delay := float64(base) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxDelay) {
+		return maxDelay
+	}
+	return time.Duration(delay)
+}
+
+// validate checks the policy's values and returns one message per problem
+func (p RetryPolicy) validate() []string {
+	var msgs []string
+	if p.MaxAttempts < 0 {
+		msgs = append(msgs, "max_attempts must not be negative")
+	}
+	if p.BackoffMS < 0 || p.MaxBackoffMS < 0 {
+		msgs = append(msgs, "backoff must not be negative")
+	}
+	if p.Multiplier < 0 {
+		msgs = append(msgs, "multiplier must not be negative")
+	}
+	for _, class := range p.RetryOn {
+		switch class {
+		case ErrorClassRateLimit, ErrorClassServer, ErrorClassTimeout, ErrorClassNetwork, ErrorClassOther, ErrorClassAll:
+		default:
+			msgs = append(msgs, fmt.Sprintf("unknown error class %q", class))
+		}
+	}
+	return msgs
+}
+
+// ErrorClass sorts an error into one of the ErrorClass* classes. Errors that carry an
+// HTTP status (by implementing HTTPStatus() int) are classified by it.
+func ErrorClass(err error) string {
+	// Checked first since HTTP clients wrap deadline errors in errors without a status
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var httpErr interface{ HTTPStatus() int }
+	if errors.As(err, &httpErr) {
+		switch status := httpErr.HTTPStatus(); {
+		case status == 429:
+			return ErrorClassRateLimit
+		case status == 408:
+			return ErrorClassTimeout
+		case status >= 500:
+			return ErrorClassServer
+		case status == 0:
+			return ErrorClassNetwork
+		}
+		return ErrorClassOther
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+	return ErrorClassOther
+}
+
+// retryPolicies resolves the retry policy of every node, falling back to def
+func retryPolicies(wf *Workflow, def RetryPolicy) (map[string]RetryPolicy, error) {
+	policies := make(map[string]RetryPolicy, len(wf.Nodes))
+	for _, node := range wf.Nodes {
+		p, err := DecodeRetryPolicy(node.Data["retry"])
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		if p == nil {
+			policies[node.ID] = def
+		} else {
+			policies[node.ID] = p.withDefaults(def)
+		}
+	}
+	return policies, nil
+}
+
+// sleepContext waits for d and reports false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// statusError is an error carrying an HTTP status, like llm.APIError
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string   { return fmt.Sprintf("status %d: %v", e.status, e.err) }
+func (e *statusError) Unwrap() error   { return e.err }
+func (e *statusError) HTTPStatus() int { return e.status }
+
+func TestErrorClass(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{&statusError{status: 429, err: errors.New("slow down")}, ErrorClassRateLimit},
+		{&statusError{status: 503, err: errors.New("unavailable")}, ErrorClassServer},
+		{&statusError{status: 400, err: errors.New("bad request")}, ErrorClassOther},
+		{&statusError{status: 0, err: errors.New("connection refused")}, ErrorClassNetwork},
+		{&statusError{status: 0, err: fmt.Errorf("post: %w", context.DeadlineExceeded)}, ErrorClassTimeout},
+		{context.DeadlineExceeded, ErrorClassTimeout},
+		{errors.New("boom"), ErrorClassOther},
+	} {
+		if got := ErrorClass(tc.err); got != tc.want {
+			t.Errorf("ErrorClass(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{BackoffMS: 100, MaxBackoffMS: 300, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond} {
+		if got := p.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+	if p.Retries(errors.New("boom")) {
+		t.Error("expected other errors not to be retried by default")
+	}
+	if !p.Retries(&statusError{status: 502, err: errors.New("bad gateway")}) {
+		t.Error("expected server errors to be retried by default")
+	}
+	if !(RetryPolicy{RetryOn: []string{ErrorClassTimeout}}).Retries(&statusError{err: context.DeadlineExceeded}) {
+		t.Error("expected a timeout policy to retry request deadlines")
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	wf := Workflow{ID: "give-up", Nodes: []Node{node("start", NodeTypeStart, nil)}}
+	run := newTestRun(map[string]computeFunc{
+		"start": func(ctx *Context, messages []Message) error {
+			return &statusError{status: 503, err: errors.New("unavailable")}
+		},
+	})
+
+	execCtx := NewExecutionContext(wf.ID)
+	err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{Retry: RetryPolicy{MaxAttempts: 3, BackoffMS: 1}})
+	if err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if n := run.count("start"); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+	if history := execCtx.StepHistory(); len(history) != 1 || history[0].Attempts != 3 {
+		t.Errorf("expected one history record with 3 attempts, got %+v", history)
+	}
+}
+
+func TestRetryRollsBackNodeState(t *testing.T) {
+	wf := Workflow{
+		ID: "retry",
+		Nodes: []Node{node("start", NodeTypeStart, map[string]interface{}{
+			"retry": map[string]interface{}{"max_attempts": 3.0, "backoff_ms": 1.0, "retry_on": []interface{}{ErrorClassAll}},
+		})},
+	}
+	var attempts int
+	run := newTestRun(map[string]computeFunc{
+		"start": func(ctx *Context, messages []Message) error {
+			attempts++
+			if _, ok := ctx.Execution.LoadNodeState(ctx.NodeID); ok {
+				return fmt.Errorf("attempt %d saw state left by a failed attempt", attempts)
+			}
+			ctx.Execution.StoreNodeState(ctx.NodeID, attempts)
+			ctx.Execution.SetResult(ctx.NodeID, map[string]interface{}{"result": attempts})
+			if attempts < 3 {
+				return errors.New("flaky")
+			}
+			return nil
+		},
+	})
+
+	execCtx := NewExecutionContext(wf.ID)
+	if err := ExecuteBSP(context.Background(), wf, execCtx, run.factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := execCtx.LoadNodeState("start"); state != 3 {
+		t.Errorf("expected the state of the successful attempt, got %v", state)
+	}
+	result, _ := execCtx.GetResult("start")
+	if m, _ := result.(map[string]interface{}); m["result"] != 3 || m["attempts"] != 3 {
+		t.Errorf("expected the result of the third attempt, got %v", result)
+	}
+}
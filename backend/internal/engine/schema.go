@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema used to describe node data
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	Default     interface{}        `json:"default,omitempty"`
+
+	// AnyOf lists alternative schemas, such as a string or a list of strings
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+}
+
+// Validate checks value against the schema and returns one message per violation.
+// Properties not listed in the schema are allowed.
+func (s *Schema) Validate(value interface{}) []string {
+	var errs []string
+	s.validate("", value, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, value interface{}, errs *[]string) {
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		if path != "" {
+			msg = path + ": " + msg
+		}
+		*errs = append(*errs, msg)
+	}
+
+	if s.Type != "" && !matchesType(s.Type, value) {
+		fail("expected %s, got %s", s.Type, jsonTypeOf(value))
+		return
+	}
+
+	if len(s.AnyOf) > 0 {
+		// Validate against the first alternative of the value's type so its errors are reported
+		var types []string
+		matched := false
+		for _, alt := range s.AnyOf {
+			if alt.Type == "" || matchesType(alt.Type, value) {
+				alt.validate(path, value, errs)
+				matched = true
+				break
+			}
+			types = append(types, alt.Type)
+		}
+		if !matched {
+			fail("expected %s, got %s", strings.Join(types, " or "), jsonTypeOf(value))
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if valuesEqual(allowed, value, false) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %v", s.Enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if s.MinLength != nil && len(strings.TrimSpace(v)) < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if field, ok := v[name]; !ok || field == nil {
+				fail("missing required field %q", name)
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if field, ok := v[name]; ok && field != nil {
+				s.Properties[name].validate(joinPath(path, name), field, errs)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func matchesType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonTypeOf(value) == schemaType
+	}
+}
+
+// jsonTypeOf names the JSON type of a value decoded by encoding/json
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxWorkflowDepth bounds how deeply sub-workflows can nest when BSPOptions.MaxDepth isn't set
+const DefaultMaxWorkflowDepth = 5
+
+// runInfo describes the run a vertex belongs to, so nested runs can inherit its settings
+type runInfo struct {
+	factory  VertexFactory
+	opts     BSPOptions
+	stack    []string // workflow IDs from the outermost run down to this one
+	policies map[string]RetryPolicy
+}
+
+// RunSubWorkflow executes wf with the given inputs as a nested run of the current workflow and
+// returns its execution context. The nested run uses the same vertex factory, worker count,
+// default retry policy and secret config entries, and is not checkpointed. Recursive calls and runs nested deeper than
+// BSPOptions.MaxDepth fail before anything is executed.
+func (c *Context) RunSubWorkflow(wf Workflow, inputs map[string]interface{}) (*ExecutionContext, error) {
+	if c.run == nil {
+		return nil, fmt.Errorf("sub-workflows can only be run from within ExecuteBSP")
+	}
+
+	chain := append(append([]string(nil), c.run.stack...), wf.ID)
+	for _, id := range c.run.stack {
+		if id == wf.ID {
+			return nil, fmt.Errorf("recursive sub-workflow call: %s", strings.Join(chain, " -> "))
+		}
+	}
+	maxDepth := c.run.opts.MaxDepth
+	if maxDepth < 1 {
+		maxDepth = DefaultMaxWorkflowDepth
+	}
+	if len(chain) > maxDepth {
+		return nil, fmt.Errorf("sub-workflow nesting exceeds the limit of %d: %s", maxDepth, strings.Join(chain, " -> "))
+	}
+
+	// Nested runs use the credentials the current run was started with
+	_, secrets := SplitSecrets(*c.Workflow)
+	wf = WithSecrets(wf, secrets)
+
+	execCtx := NewExecutionContext(wf.ID)
+	if inputs != nil {
+		execCtx.Inputs = inputs
+	}
+	opts := BSPOptions{
+		NumWorkers: c.run.opts.NumWorkers,
+		Retry:      c.run.opts.Retry,
+		MaxDepth:   c.run.opts.MaxDepth,
+		Observers:  c.run.opts.Observers,
+		parents:    c.run.stack,
+	}
+	err := ExecuteBSP(c.Ctx, wf, execCtx, c.run.factory, opts)
+	return execCtx, err
+}
+
+// validateSubWorkflow checks a SUBWORKFLOW node's target and input mapping. Each mapped input is
+// an expression like those of workflow outputs, e.g. "nodes.summarize.result | trim".
+func validateSubWorkflow(wf Workflow, node *Node, nodes map[string]*Node, adj map[string][]string) []string {
+	if node.Type != NodeTypeSubWorkflow {
+		return nil
+	}
+
+	var msgs []string
+	if target, _ := node.Data["workflow_id"].(string); target != "" && target == wf.ID {
+		msgs = append(msgs, "a workflow cannot run itself as a sub-workflow")
+	}
+
+	mapping, _ := node.Data["inputs"].(map[string]interface{})
+	names := make([]string, 0, len(mapping))
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expr, ok := mapping[name].(string)
+		if !ok {
+			msgs = append(msgs, fmt.Sprintf("inputs.%s: expected an expression string", name))
+			continue
+		}
+		if msg := validateExpression(wf, node, nodes, adj, expr); msg != "" {
+			msgs = append(msgs, fmt.Sprintf("inputs.%s: %s", name, msg))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// callerRun returns a test run whose "call-<id>" nodes run workflows[<id>] as a sub-workflow
+func callerRun(workflows map[string]Workflow) *testRun {
+	behavior := map[string]computeFunc{}
+	for id := range workflows {
+		target := id
+		behavior["call-"+target] = func(ctx *Context, messages []Message) error {
+			if _, err := ctx.RunSubWorkflow(workflows[target], nil); err != nil {
+				return err
+			}
+			return forward(ctx, messages)
+		}
+	}
+	return newTestRun(behavior)
+}
+
+// callingWorkflow is a workflow with ID id whose only node after the start calls callee
+func callingWorkflow(id, callee string) Workflow {
+	wf := Workflow{ID: id, Nodes: []Node{node("start-"+id, NodeTypeStart, nil)}}
+	if callee != "" {
+		wf.Nodes = append(wf.Nodes, node("call-"+callee, NodeTypeSubWorkflow, nil))
+		wf.Edges = []Edge{edge("start-"+id, "call-"+callee)}
+	}
+	return wf
+}
+
+func TestSubWorkflowRecursionFails(t *testing.T) {
+	workflows := map[string]Workflow{"a": callingWorkflow("a", "b"), "b": callingWorkflow("b", "a")}
+	run := callerRun(workflows)
+
+	err := ExecuteBSP(context.Background(), workflows["a"], NewExecutionContext("a"), run.factory(), BSPOptions{})
+	if err == nil || !strings.Contains(err.Error(), "recursive sub-workflow call: a -> b -> a") {
+		t.Fatalf("expected a recursion error, got %v", err)
+	}
+	if run.count("start-a") != 1 {
+		t.Errorf("expected workflow a not to start again, its start node ran %d times", run.count("start-a"))
+	}
+}
+
+func TestSubWorkflowDepthLimit(t *testing.T) {
+	workflows := map[string]Workflow{
+		"d1": callingWorkflow("d1", "d2"),
+		"d2": callingWorkflow("d2", "d3"),
+		"d3": callingWorkflow("d3", ""),
+	}
+
+	run := callerRun(workflows)
+	err := ExecuteBSP(context.Background(), workflows["d1"], NewExecutionContext("d1"), run.factory(), BSPOptions{MaxDepth: 2})
+	if err == nil || !strings.Contains(err.Error(), "nesting exceeds the limit of 2: d1 -> d2 -> d3") {
+		t.Fatalf("expected a depth error, got %v", err)
+	}
+	if run.count("start-d3") != 0 {
+		t.Error("expected the workflow beyond the limit not to run")
+	}
+
+	run = callerRun(workflows)
+	if err := ExecuteBSP(context.Background(), workflows["d1"], NewExecutionContext("d1"), run.factory(), BSPOptions{MaxDepth: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if run.count("start-d3") != 1 {
+		t.Errorf("expected the innermost workflow to run once, got %d", run.count("start-d3"))
+	}
+}
+
+func TestSubWorkflowInheritsInputsAndSecrets(t *testing.T) {
+	inner := Workflow{ID: "inner", Nodes: []Node{node("inner-start", NodeTypeStart, nil)}}
+	outer := Workflow{
+		ID:     "outer",
+		Config: map[string]string{"openai_api_key": "sk-test", "region": "eu"},
+		Nodes:  []Node{node("start", NodeTypeStart, nil), node("sub", NodeTypeSubWorkflow, nil)},
+		Edges:  []Edge{edge("start", "sub")},
+	}
+
+	var mu sync.Mutex
+	var seen map[string]string
+	var inputs map[string]interface{}
+	run := newTestRun(map[string]computeFunc{
+		"sub": func(ctx *Context, messages []Message) error {
+			child, err := ctx.RunSubWorkflow(inner, map[string]interface{}{"topic": "Go"})
+			if err != nil {
+				return err
+			}
+			inputs = child.Inputs
+			return forward(ctx, messages)
+		},
+		"inner-start": func(ctx *Context, messages []Message) error {
+			mu.Lock()
+			seen = ctx.Workflow.Config
+			mu.Unlock()
+			return forward(ctx, messages)
+		},
+	})
+
+	if err := ExecuteBSP(context.Background(), outer, NewExecutionContext(outer.ID), run.factory(), BSPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if seen["openai_api_key"] != "sk-test" || seen["region"] != "" {
+		t.Errorf("expected the nested run to get the secret config entries only, got %v", seen)
+	}
+	if fmt.Sprint(inputs) != "map[topic:Go]" {
+		t.Errorf("expected the nested run's inputs, got %v", inputs)
+	}
+}
+
+func TestRunSubWorkflowOutsideExecuteBSP(t *testing.T) {
+	ctx := &Context{Ctx: context.Background(), NodeID: "sub", Workflow: &Workflow{ID: "wf"}, Execution: NewExecutionContext("wf")}
+	if _, err := ctx.RunSubWorkflow(Workflow{ID: "inner"}, nil); err == nil {
+		t.Fatal("expected RunSubWorkflow to fail without a running workflow")
+	}
+}
@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Template namespaces
+const (
+	TemplateNodes      = "nodes"
+	TemplateInputs     = "inputs"
+	TemplateAggregates = "aggregates"
+)
+
+// templateFilters are the filters a template expression can pipe values through
+var templateFilters = map[string]func(value interface{}, arg string, hasArg bool) (interface{}, error){
+	"upper": func(v interface{}, _ string, _ bool) (interface{}, error) { return strings.ToUpper(stringify(v)), nil },
+	"lower": func(v interface{}, _ string, _ bool) (interface{}, error) { return strings.ToLower(stringify(v)), nil },
+	"trim": func(v interface{}, _ string, _ bool) (interface{}, error) {
+		return strings.TrimSpace(stringify(v)), nil
+	},
+	"json": func(v interface{}, _ string, _ bool) (interface{}, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"default": func(v interface{}, arg string, _ bool) (interface{}, error) {
+		if v == nil || stringify(v) == "" {
+			return arg, nil
+		}
+		return v, nil
+	},
+	"truncate": func(v interface{}, arg string, hasArg bool) (interface{}, error) {
+		if !hasArg {
+			return nil, fmt.Errorf("truncate needs a length, e.g. truncate:100")
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("truncate length must be a non-negative integer, got %q", arg)
+		}
+		runes := []rune(stringify(v))
+		if len(runes) > n {
+			runes = runes[:n]
+		}
+		return string(runes), nil
+	},
+	"join": func(v interface{}, arg string, hasArg bool) (interface{}, error) {
+		items, ok := v.([]interface{})
+		if !ok {
+			return v, nil
+		}
+		sep := ", "
+		if hasArg {
+			sep = arg
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = stringify(item)
+		}
+		return strings.Join(parts, sep), nil
+	},
+}
+
+// TemplateRef is a reference such as nodes.summarize.result or inputs.topic
+type TemplateRef struct {
+	Namespace string   // "nodes", "inputs" or "aggregates"
+	Key       string   // node ID, input name or aggregator name
+	Path      []string // remaining path into the value
+}
+
+func (r TemplateRef) String() string {
+	return strings.Join(append([]string{r.Namespace, r.Key}, r.Path...), ".")
+}
+
+type templateFilter struct {
+	name   string
+	arg    string
+	hasArg bool
+}
+
+type templatePart struct {
+	text    string
+	ref     *TemplateRef
+	filters []templateFilter
+}
+
+// Template is a parsed prompt template with {{ ref | filter:arg }} expressions
+type Template struct {
+	parts []templatePart
+}
+
+// TemplateScope supplies the values template references resolve to
+type TemplateScope struct {
+	// Node returns the output of a node, or false if it hasn't produced one
+	Node       func(nodeID string) (map[string]interface{}, bool)
+	Inputs     map[string]interface{}
+	Aggregates map[string]interface{}
+}
+
+// ParseTemplate parses a template. Text outside {{ }} is kept verbatim.
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{}
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			if s != "" {
+				t.parts = append(t.parts, templatePart{text: s})
+			}
+			return t, nil
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed expression %q", truncateForError(s[start:]))
+		}
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{text: s[:start]})
+		}
+
+		part, err := parseExpression(s[start+2 : start+end])
+		if err != nil {
+			return nil, err
+		}
+		t.parts = append(t.parts, part)
+		s = s[start+end+2:]
+	}
+}
+
+func parseExpression(expr string) (templatePart, error) {
+	segments := strings.Split(expr, "|")
+	refText := strings.TrimSpace(segments[0])
+	path := strings.Split(refText, ".")
+	if len(path) < 2 || path[0] == "" || path[1] == "" {
+		return templatePart{}, fmt.Errorf("invalid reference %q, expected nodes.<id>.<field>, inputs.<name> or aggregates.<name>", refText)
+	}
+	if path[0] != TemplateNodes && path[0] != TemplateInputs && path[0] != TemplateAggregates {
+		return templatePart{}, fmt.Errorf("unknown namespace %q in %q, expected %q, %q or %q", path[0], refText, TemplateNodes, TemplateInputs, TemplateAggregates)
+	}
+	ref := &TemplateRef{Namespace: path[0], Key: path[1], Path: path[2:]}
+
+	part := templatePart{ref: ref}
+	for _, seg := range segments[1:] {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(seg), ":")
+		name = strings.TrimSpace(name)
+		if _, ok := templateFilters[name]; !ok {
+			return templatePart{}, fmt.Errorf("unknown filter %q in {{%s}}", name, expr)
+		}
+		arg = strings.TrimSpace(arg)
+		if unquoted, err := strconv.Unquote(arg); err == nil {
+			arg = unquoted
+		}
+		part.filters = append(part.filters, templateFilter{name: name, arg: arg, hasArg: hasArg})
+	}
+	return part, nil
+}
+
+func truncateForError(s string) string {
+	if len(s) > 30 {
+		return s[:30] + "..."
+	}
+	return s
+}
+
+func (p templatePart) hasFilter(name string) bool {
+	for _, f := range p.filters {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Refs returns the references used by the template in order of appearance
+func (t *Template) Refs() []TemplateRef {
+	var refs []TemplateRef
+	for _, part := range t.parts {
+		if part.ref != nil {
+			refs = append(refs, *part.ref)
+		}
+	}
+	return refs
+}
+
+// EvalExpression evaluates a single expression such as "nodes.a.result | trim" (without braces)
+// and returns its value without converting it to text
+func EvalExpression(expr string, scope TemplateScope) (interface{}, error) {
+	part, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	return part.eval(scope)
+}
+
+func (p templatePart) eval(scope TemplateScope) (interface{}, error) {
+	value, err := scope.resolve(*p.ref)
+	if err != nil && !p.hasFilter("default") {
+		return nil, err
+	}
+	for _, f := range p.filters {
+		if value, err = templateFilters[f.name](value, f.arg, f.hasArg); err != nil {
+			return nil, fmt.Errorf("{{%s}}: %w", p.ref, err)
+		}
+	}
+	return value, nil
+}
+
+// Render evaluates the template against scope
+func (t *Template) Render(scope TemplateScope) (string, error) {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.ref == nil {
+			b.WriteString(part.text)
+			continue
+		}
+
+		value, err := part.eval(scope)
+		if err != nil {
+			return "", err
+		}
+
+		switch v := value.(type) {
+		case string:
+			b.WriteString(v)
+		case map[string]interface{}, []interface{}:
+			data, _ := json.Marshal(v)
+			b.Write(data)
+		default:
+			b.WriteString(stringify(v))
+		}
+	}
+	return b.String(), nil
+}
+
+func (s TemplateScope) resolve(ref TemplateRef) (interface{}, error) {
+	var root map[string]interface{}
+	switch ref.Namespace {
+	case TemplateNodes:
+		ok := false
+		if s.Node != nil {
+			root, ok = s.Node(ref.Key)
+		}
+		if !ok {
+			return nil, fmt.Errorf("{{%s}}: node %q has not produced a result", ref, ref.Key)
+		}
+	case TemplateInputs:
+		root = s.Inputs
+	case TemplateAggregates:
+		root = s.Aggregates
+	}
+
+	path := ref.Path
+	if ref.Namespace != TemplateNodes {
+		path = append([]string{ref.Key}, ref.Path...)
+	}
+	if len(path) == 0 {
+		return root, nil
+	}
+	value, ok := lookupPath(root, strings.Join(path, "."))
+	if !ok {
+		return nil, fmt.Errorf("{{%s}}: no such value", ref)
+	}
+	return value, nil
+}
+
+// TemplateScope builds the scope for rendering a template during Compute. A node reference
+// resolves to the incoming message from that node, falling back to the node's stored result.
+func (c *Context) TemplateScope(messages []Message) TemplateScope {
+	return TemplateScope{
+		Node: func(nodeID string) (map[string]interface{}, bool) {
+			for i := len(messages) - 1; i >= 0; i-- {
+				if messages[i].From == nodeID {
+					return messages[i].Content, true
+				}
+			}
+			if result, ok := c.Execution.GetResult(nodeID); ok {
+				if m, ok := result.(map[string]interface{}); ok {
+					return m, true
+				}
+			}
+			return nil, false
+		},
+		Inputs:     c.Execution.Inputs,
+		Aggregates: c.Execution.aggregateSnapshot(),
+	}
+}
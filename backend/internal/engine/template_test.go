@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+// templateScope resolves nodes.summarize and the topic and tags inputs
+func templateScope() TemplateScope {
+	return TemplateScope{
+		Node: func(nodeID string) (map[string]interface{}, bool) {
+			if nodeID != "summarize" {
+				return nil, false
+			}
+			return map[string]interface{}{"result": "  A short summary.  ", "meta": map[string]interface{}{"words": 3.0}}, true
+		},
+		Inputs:     map[string]interface{}{"topic": "Go", "tags": []interface{}{"fast", "simple"}, "empty": ""},
+		Aggregates: map[string]interface{}{"best": 0.9},
+	}
+}
+
+func TestTemplateFilters(t *testing.T) {
+	tests := []struct {
+		template, want string
+	}{
+		{"Topic: {{inputs.topic}}", "Topic: Go"},
+		{"{{ inputs.topic | upper }}", "GO"},
+		{"{{inputs.topic|lower}}", "go"},
+		{"[{{nodes.summarize.result | trim}}]", "[A short summary.]"},
+		{"{{nodes.summarize.result | trim | truncate:7}}", "A short"},
+		{"{{nodes.summarize.result | trim | upper | truncate:1}}", "A"},
+		{"{{inputs.tags | join}}", "fast, simple"},
+		{`{{inputs.tags | join:" / "}}`, "fast / simple"},
+		{"{{inputs.tags | json}}", `["fast","simple"]`},
+		{"{{inputs.tags}}", `["fast","simple"]`},
+		{"{{nodes.summarize.meta}}", `{"words":3}`},
+		{"{{nodes.summarize.meta.words}}", "3"},
+		{"{{inputs.empty | default:none}}", "none"},
+		{"{{inputs.missing | default:\"n/a\"}}", "n/a"},
+		{"{{nodes.translate.result | default:untranslated}}", "untranslated"},
+		{"{{aggregates.best}}", "0.9"},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.template)
+		if err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+			continue
+		}
+		got, err := tmpl.Render(templateScope())
+		if err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+		} else if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.template, tt.want, got)
+		}
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	parseErrors := []struct {
+		template, want string
+	}{
+		{"{{inputs.topic", "unclosed expression"},
+		{"{{topic}}", "invalid reference"},
+		{"{{outputs.topic}}", "unknown namespace"},
+		{"{{inputs.topic | shout}}", "unknown filter"},
+	}
+	for _, tt := range parseErrors {
+		if _, err := ParseTemplate(tt.template); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.template, tt.want, err)
+		}
+	}
+
+	renderErrors := []struct {
+		template, want string
+	}{
+		{"{{nodes.translate.result}}", "has not produced a result"},
+		{"{{inputs.missing}}", "no such value"},
+		{"{{inputs.topic | truncate}}", "truncate needs a length"},
+		{"{{inputs.topic | truncate:-1}}", "non-negative integer"},
+	}
+	for _, tt := range renderErrors {
+		tmpl, err := ParseTemplate(tt.template)
+		if err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+			continue
+		}
+		if _, err := tmpl.Render(templateScope()); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.template, tt.want, err)
+		}
+	}
+}
+
+func TestEvalExpressionKeepsValueType(t *testing.T) {
+	value, err := EvalExpression("inputs.tags", templateScope())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags, ok := value.([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("expected the tags list, got %#v", value)
+	}
+}
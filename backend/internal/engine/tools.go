@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// DefaultToolIterations bounds the tool-call loop of an LLM node that doesn't set data.max_tool_iterations
+const DefaultToolIterations = 5
+
+var toolNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ToolSpec is a tool an LLM node offers the model, set in data.tools. A tool runs either a node of
+// the same workflow, which then only runs as a tool, or a saved workflow. The arguments of a call
+// become the inputs of that run.
+type ToolSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Node        string `json:"node,omitempty"`
+	WorkflowID  string `json:"workflow_id,omitempty"`
+	Version     int    `json:"version,omitempty"`
+	// Parameters is the JSON Schema of the arguments. Workflow tools default to the workflow's inputs.
+	Parameters *Schema `json:"parameters,omitempty"`
+}
+
+// DecodeTools converts data.tools into tool specs
+func DecodeTools(v interface{}) ([]ToolSpec, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tools []ToolSpec
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return nil, fmt.Errorf("invalid tools: %w", err)
+	}
+	return tools, nil
+}
+
+// InputsSchema describes the inputs of a workflow as an object schema, e.g. for tool arguments
+func InputsSchema(wf Workflow) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, param := range wf.Inputs {
+		prop := inputSchema(param)
+		prop.Description = param.Description
+		schema.Properties[param.Name] = prop
+		if param.Required && param.Default == nil {
+			schema.Required = append(schema.Required, param.Name)
+		}
+	}
+	return schema
+}
+
+// ToolNodeWorkflow builds the one-node workflow that runs a node tool. Its inputs are the
+// tool's parameters, so the node can use {{inputs.<parameter>}} in its templates.
+func ToolNodeWorkflow(wf *Workflow, tool ToolSpec) (*Workflow, error) {
+	node := nodeByID(wf, tool.Node)
+	if node == nil {
+		return nil, fmt.Errorf("tool %s: unknown node %q", tool.Name, tool.Node)
+	}
+
+	inner := &Workflow{ID: wf.ID + "/" + node.ID, Nodes: []Node{*node}}
+	if tool.Parameters == nil {
+		return inner, nil
+	}
+	required := make(map[string]bool, len(tool.Parameters.Required))
+	for _, name := range tool.Parameters.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(tool.Parameters.Properties))
+	for name := range tool.Parameters.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := tool.Parameters.Properties[name]
+		param := InputParam{Name: name, Type: "any", Required: required[name]}
+		if prop != nil {
+			param.Description = prop.Description
+			if inputTypes[prop.Type] {
+				param.Type = prop.Type
+			}
+		}
+		inner.Inputs = append(inner.Inputs, param)
+	}
+	return inner, nil
+}
+
+// toolNodes returns the IDs of the nodes used as tools and the tool each one implements
+func toolNodes(wf *Workflow) map[string]ToolSpec {
+	nodes := map[string]ToolSpec{}
+	for _, node := range wf.Nodes {
+		tools, _ := DecodeTools(node.Data["tools"])
+		for _, tool := range tools {
+			if tool.Node != "" {
+				nodes[tool.Node] = tool
+			}
+		}
+	}
+	return nodes
+}
+
+// validateTools checks the tools of an LLM node
+func validateTools(wf Workflow, node *Node, nodes map[string]*Node) []string {
+	tools, err := DecodeTools(node.Data["tools"])
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var msgs []string
+	names := map[string]bool{}
+	for i, tool := range tools {
+		if !toolNamePattern.MatchString(tool.Name) {
+			msgs = append(msgs, fmt.Sprintf("tools[%d]: invalid name %q", i, tool.Name))
+		} else if names[tool.Name] {
+			msgs = append(msgs, fmt.Sprintf("tools[%d]: duplicate name %q", i, tool.Name))
+		}
+		names[tool.Name] = true
+
+		switch {
+		case tool.Node != "" && tool.WorkflowID != "":
+			msgs = append(msgs, fmt.Sprintf("tool %s: set either node or workflow_id, not both", tool.Name))
+		case tool.Node == "" && tool.WorkflowID == "":
+			msgs = append(msgs, fmt.Sprintf("tool %s: set node or workflow_id to choose what the tool runs", tool.Name))
+		case tool.Node == node.ID:
+			msgs = append(msgs, fmt.Sprintf("tool %s: a node cannot be its own tool", tool.Name))
+		case tool.WorkflowID != "" && tool.WorkflowID == wf.ID:
+			msgs = append(msgs, fmt.Sprintf("tool %s: a workflow cannot be its own tool", tool.Name))
+		case tool.Node != "":
+			if _, ok := nodes[tool.Node]; !ok {
+				msgs = append(msgs, fmt.Sprintf("tool %s: unknown node %q", tool.Name, tool.Node))
+			}
+			for _, edge := range wf.Edges {
+				if edge.Source == tool.Node || edge.Target == tool.Node {
+					msgs = append(msgs, fmt.Sprintf("tool %s: node %q runs only as a tool and cannot have edges", tool.Name, tool.Node))
+					break
+				}
+			}
+		}
+		if tool.Parameters != nil && tool.Parameters.Type != "object" {
+			msgs = append(msgs, fmt.Sprintf("tool %s: parameters must be an object schema", tool.Name))
+		}
+	}
+	return msgs
+}
@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // NodeType represents the type of work a node does
@@ -13,8 +16,39 @@ const (
 	NodeTypeEnd    NodeType = "END"
 	NodeTypeLLM    NodeType = "LLM"
 	NodeTypeResult NodeType = "RESULT"
+	NodeTypeRouter NodeType = "ROUTER"
+	NodeTypeLoop   NodeType = "LOOP"
+	// NodeTypeSubWorkflow runs a saved workflow as a nested execution
+	NodeTypeSubWorkflow NodeType = "SUBWORKFLOW"
+	// NodeTypeMap runs an inner node or saved workflow once per item of a list
+	NodeTypeMap NodeType = "MAP"
+	// NodeTypeJoin waits for its parents and merges what they delivered
+	NodeTypeJoin NodeType = "JOIN"
+	// NodeTypeChat is an LLM node that keeps a conversation transcript
+	NodeTypeChat NodeType = "CHAT"
 )
 
+// Source handles of a LOOP node. Edges leaving through LoopExitHandle are taken when the
+// loop finishes; all other outgoing edges form the loop body.
+const (
+	LoopBodyHandle = "body"
+	LoopExitHandle = "exit"
+)
+
+// ErrorHandle is the source handle of error edges, taken only when the node fails with
+// data.on_error set to OnErrorRoute
+const ErrorHandle = "error"
+
+// Failure handling modes a node selects with data.on_error
+const (
+	OnErrorFail     = "fail"     // abort the run (default)
+	OnErrorContinue = "continue" // send an error message to the node's children
+	OnErrorRoute    = "route"    // send an error message along the node's error edges
+)
+
+// DefaultMaxSupersteps bounds a run when the workflow doesn't set its own limit
+const DefaultMaxSupersteps = 100
+
 // Position represents the x and y coordinates of a node
 type Position struct {
 	X float64 `json:"x"`
@@ -30,6 +64,14 @@ type Node struct {
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// Label returns the display label of the node, falling back to its ID
+func (n *Node) Label() string {
+	if label, ok := n.Data["label"].(string); ok && label != "" {
+		return label
+	}
+	return n.ID
+}
+
 // Message represents data passed between nodes
 type Message struct {
 	From    string                 `json:"from"`
@@ -39,9 +81,11 @@ type Message struct {
 
 // Edge represents a connection between nodes
 type Edge struct {
-	ID     string `json:"id"`
-	Source string `json:"source"`
-	Target string `json:"target"`
+	ID           string     `json:"id"`
+	Source       string     `json:"source"`
+	Target       string     `json:"target"`
+	SourceHandle string     `json:"sourceHandle,omitempty"` // set by the editor for nodes with several outputs
+	Condition    *Condition `json:"condition,omitempty"`    // nil means the edge is always taken
 }
 
 // Workflow represents the entire graph
@@ -50,30 +94,234 @@ type Workflow struct {
 	Nodes  []Node            `json:"nodes"`
 	Edges  []Edge            `json:"edges"`
 	Config map[string]string `json:"config,omitempty"`
+	// MaxSupersteps overrides DefaultMaxSupersteps for this workflow
+	MaxSupersteps int `json:"max_supersteps,omitempty"`
+	// Inputs are the parameters a run can be started with; Outputs are the values it reports
+	Inputs  []InputParam  `json:"inputs,omitempty"`
+	Outputs []OutputParam `json:"outputs,omitempty"`
+	// Aggregators combine values contributed by vertices into values readable in the next superstep
+	Aggregators []AggregatorSpec `json:"aggregators,omitempty"`
+}
+
+// IsSecretConfigKey reports whether a workflow config entry holds a credential such as
+// openai_api_key. Secret entries are kept out of the job stream and the run history.
+func IsSecretConfigKey(key string) bool {
+	return key == "api_key" || strings.HasSuffix(key, "_api_key")
+}
+
+// SplitSecrets returns a copy of wf without its secret config entries, together with the
+// entries it removed (nil if there were none)
+func SplitSecrets(wf Workflow) (Workflow, map[string]string) {
+	var secrets map[string]string
+	config := make(map[string]string, len(wf.Config))
+	for k, v := range wf.Config {
+		if IsSecretConfigKey(k) {
+			if secrets == nil {
+				secrets = make(map[string]string)
+			}
+			secrets[k] = v
+			continue
+		}
+		config[k] = v
+	}
+	if wf.Config != nil {
+		wf.Config = config
+	}
+	return wf, secrets
+}
+
+// WithSecrets returns a copy of wf with secrets merged back into its config
+func WithSecrets(wf Workflow, secrets map[string]string) Workflow {
+	if len(secrets) == 0 {
+		return wf
+	}
+	config := make(map[string]string, len(wf.Config)+len(secrets))
+	for k, v := range wf.Config {
+		config[k] = v
+	}
+	for k, v := range secrets {
+		config[k] = v
+	}
+	wf.Config = config
+	return wf
 }
 
 // ExecutionStatus represents the state of a node execution
 type ExecutionStatus string
 
 const (
-	StatusPending ExecutionStatus = "PENDING"
-	StatusRunning ExecutionStatus = "RUNNING"
-	StatusSuccess ExecutionStatus = "SUCCESS"
-	StatusFailed  ExecutionStatus = "FAILED"
+	StatusPending   ExecutionStatus = "PENDING"
+	StatusRunning   ExecutionStatus = "RUNNING"
+	StatusSuccess   ExecutionStatus = "SUCCESS"
+	StatusFailed    ExecutionStatus = "FAILED"
+	StatusCancelled ExecutionStatus = "CANCELLED"
+	StatusTimedOut  ExecutionStatus = "TIMED_OUT"
+	StatusSkipped   ExecutionStatus = "SKIPPED"
 )
 
+// StepRecord captures one Compute call of a node within a superstep
+type StepRecord struct {
+	Step   int             `json:"step"`
+	NodeID string          `json:"node_id"`
+	Status ExecutionStatus `json:"status"`
+	Result interface{}     `json:"result,omitempty"`
+	// Error is the error of the last failed attempt. A SUCCESS record has one if the node
+	// only succeeded after retrying.
+	Error      string    `json:"error,omitempty"`
+	Attempts   int       `json:"attempts,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
 // ExecutionContext holds the state of a running workflow
 type ExecutionContext struct {
 	WorkflowID string
-	Status     map[string]ExecutionStatus
-	Results    map[string]interface{}
+	// Inputs are the workflow inputs of this run, available to templates as inputs.<name>
+	Inputs map[string]interface{}
+	// Outputs are the declared workflow outputs, collected when the run finishes
+	Outputs map[string]interface{}
+	Status  map[string]ExecutionStatus
+	Results map[string]interface{}
+	History []StepRecord
+	// NodeState holds per-node state that must survive across supersteps (e.g. loop counters)
+	NodeState map[string]interface{}
+	// Aggregates are the aggregator values as of the last superstep barrier
+	Aggregates map[string]interface{}
 	mu         sync.RWMutex
 }
 
 func NewExecutionContext(wfID string) *ExecutionContext {
 	return &ExecutionContext{
 		WorkflowID: wfID,
+		Inputs:     make(map[string]interface{}),
+		Outputs:    make(map[string]interface{}),
 		Status:     make(map[string]ExecutionStatus),
 		Results:    make(map[string]interface{}),
+		NodeState:  make(map[string]interface{}),
+		Aggregates: make(map[string]interface{}),
+	}
+}
+
+// LoadNodeState returns the state a node stored in an earlier superstep. Safe for concurrent use.
+func (e *ExecutionContext) LoadNodeState(nodeID string) (interface{}, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	state, ok := e.NodeState[nodeID]
+	return state, ok
+}
+
+// StoreNodeState replaces the state of a node; nil clears it. Safe for concurrent use.
+func (e *ExecutionContext) StoreNodeState(nodeID string, state interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if state == nil {
+		delete(e.NodeState, nodeID)
+		return
+	}
+	e.NodeState[nodeID] = state
+}
+
+// nodeSnapshot is the result and state of a single node, taken before it computes
+type nodeSnapshot struct {
+	result, state       interface{}
+	hasResult, hasState bool
+}
+
+// snapshotNode captures the result and state of a node. Safe for concurrent use.
+func (e *ExecutionContext) snapshotNode(nodeID string) nodeSnapshot {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var s nodeSnapshot
+	s.result, s.hasResult = e.Results[nodeID]
+	s.state, s.hasState = e.NodeState[nodeID]
+	return s
+}
+
+// restoreNode puts back the result and state of a node captured by snapshotNode.
+// Safe for concurrent use.
+func (e *ExecutionContext) restoreNode(nodeID string, s nodeSnapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s.hasResult {
+		e.Results[nodeID] = s.result
+	} else {
+		delete(e.Results, nodeID)
+	}
+	if s.hasState {
+		e.NodeState[nodeID] = s.state
+	} else {
+		delete(e.NodeState, nodeID)
+	}
+}
+
+// SetResult stores the result of a node. Safe for concurrent use.
+func (e *ExecutionContext) SetResult(nodeID string, result interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Results[nodeID] = result
+}
+
+// GetResult returns the stored result of a node, if any. Safe for concurrent use.
+func (e *ExecutionContext) GetResult(nodeID string) (interface{}, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	result, ok := e.Results[nodeID]
+	return result, ok
+}
+
+// SetStatus records the execution status of a node. Safe for concurrent use.
+func (e *ExecutionContext) SetStatus(nodeID string, status ExecutionStatus) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Status[nodeID] = status
+}
+
+// GetStatus returns the execution status of a node. Safe for concurrent use.
+func (e *ExecutionContext) GetStatus(nodeID string) ExecutionStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.Status[nodeID]
+}
+
+// RecordStep appends an entry to the execution history. Safe for concurrent use.
+func (e *ExecutionContext) RecordStep(record StepRecord) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.History = append(e.History, record)
+}
+
+// StepHistory returns a copy of the execution history. Safe for concurrent use.
+func (e *ExecutionContext) StepHistory() []StepRecord {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]StepRecord(nil), e.History...)
+}
+
+// FailedNodes returns the sorted IDs of the nodes whose last execution failed. Safe for concurrent use.
+func (e *ExecutionContext) FailedNodes() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var failed []string
+	for id, status := range e.Status {
+		if status == StatusFailed {
+			failed = append(failed, id)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// Snapshot returns copies of the status and result maps. Safe for concurrent use.
+func (e *ExecutionContext) Snapshot() (map[string]ExecutionStatus, map[string]interface{}) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	status := make(map[string]ExecutionStatus, len(e.Status))
+	for k, v := range e.Status {
+		status[k] = v
+	}
+	results := make(map[string]interface{}, len(e.Results))
+	for k, v := range e.Results {
+		results[k] = v
 	}
+	return status, results
 }
@@ -0,0 +1,381 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Validation issue codes
+const (
+	IssueMissingID    = "missing_id"
+	IssueDuplicateID  = "duplicate_id"
+	IssueUnknownType  = "unknown_type"
+	IssueMissingData  = "missing_data"
+	IssueInvalidData  = "invalid_data"
+	IssueDanglingEdge = "dangling_edge"
+	IssueInvalidEdge  = "invalid_edge"
+	IssueUnreachable  = "unreachable"
+	IssueCycle        = "unintended_cycle"
+	IssueTemplate     = "invalid_template"
+	IssueInputOutput  = "invalid_io"
+	IssueAggregator   = "invalid_aggregator"
+)
+
+// ValidationIssue is a single problem found in a workflow definition
+type ValidationIssue struct {
+	Code    string   `json:"code"`
+	NodeID  string   `json:"node_id,omitempty"`
+	EdgeID  string   `json:"edge_id,omitempty"`
+	NodeIDs []string `json:"node_ids,omitempty"`
+	Message string   `json:"message"`
+}
+
+// ValidationError reports every problem found by Validate
+type ValidationError struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.Message
+	}
+	return fmt.Sprintf("invalid workflow: %s", strings.Join(msgs, "; "))
+}
+
+// Validate checks a workflow definition before execution and reports all problems at once.
+// Node types and their data are checked against registry; a nil registry skips those checks.
+// It returns nil if the workflow is valid, or a *ValidationError.
+func Validate(wf Workflow, registry *Registry) error {
+	var issues []ValidationIssue
+	add := func(issue ValidationIssue) {
+		issues = append(issues, issue)
+	}
+
+	// Nodes: IDs, types and required data
+	nodes := make(map[string]*Node, len(wf.Nodes))
+	for i := range wf.Nodes {
+		node := &wf.Nodes[i]
+		if node.ID == "" {
+			add(ValidationIssue{Code: IssueMissingID, Message: fmt.Sprintf("node #%d has no id", i)})
+			continue
+		}
+		if _, exists := nodes[node.ID]; exists {
+			add(ValidationIssue{Code: IssueDuplicateID, NodeID: node.ID, Message: fmt.Sprintf("duplicate node id %q", node.ID)})
+			continue
+		}
+		nodes[node.ID] = node
+
+		if registry != nil {
+			spec, known := registry.Lookup(node.Type)
+			if !known {
+				add(ValidationIssue{Code: IssueUnknownType, NodeID: node.ID, Message: fmt.Sprintf("node %q has unknown type %q", node.ID, node.Type)})
+				continue
+			}
+			for _, msg := range spec.Schema.Validate(node.Data) {
+				code := IssueInvalidData
+				if strings.HasPrefix(msg, "missing required field") {
+					code = IssueMissingData
+				}
+				add(ValidationIssue{Code: code, NodeID: node.ID, Message: fmt.Sprintf("node %q (%s): %s", node.ID, node.Type, msg)})
+			}
+		}
+		msgs := append(validateNodeData(node), validateErrorHandling(node, wf)...)
+		for _, msg := range append(msgs, validateWaitFor(wf, node)...) {
+			add(ValidationIssue{Code: IssueInvalidData, NodeID: node.ID, Message: fmt.Sprintf("node %q: %s", node.ID, msg)})
+		}
+	}
+
+	// Edges: endpoints and conditions
+	edgeIDs := make(map[string]bool, len(wf.Edges))
+	adj := make(map[string][]string)
+	inDegree := make(map[string]int)
+	for _, edge := range wf.Edges {
+		if edge.ID != "" {
+			if edgeIDs[edge.ID] {
+				add(ValidationIssue{Code: IssueDuplicateID, EdgeID: edge.ID, Message: fmt.Sprintf("duplicate edge id %q", edge.ID)})
+			}
+			edgeIDs[edge.ID] = true
+		}
+
+		_, srcOK := nodes[edge.Source]
+		_, dstOK := nodes[edge.Target]
+		if !srcOK {
+			add(ValidationIssue{Code: IssueDanglingEdge, EdgeID: edge.ID, Message: fmt.Sprintf("edge %q starts at missing node %q", edge.ID, edge.Source)})
+		}
+		if !dstOK {
+			add(ValidationIssue{Code: IssueDanglingEdge, EdgeID: edge.ID, Message: fmt.Sprintf("edge %q points at missing node %q", edge.ID, edge.Target)})
+		}
+		if msg := validateCondition(edge.Condition); msg != "" {
+			add(ValidationIssue{Code: IssueInvalidEdge, EdgeID: edge.ID, Message: fmt.Sprintf("edge %q: %s", edge.ID, msg)})
+		}
+		if srcOK && dstOK {
+			adj[edge.Source] = append(adj[edge.Source], edge.Target)
+			inDegree[edge.Target]++
+		}
+	}
+
+	// Reachability from the nodes ExecuteBSP triggers
+	reached := make(map[string]bool, len(nodes))
+	tools := toolNodes(&wf)
+	var queue []string
+	for id, node := range nodes {
+		if _, isTool := tools[id]; isTool {
+			// Tool nodes only run when an LLM node calls them
+			reached[id] = true
+			continue
+		}
+		if isTriggered(node, inDegree[id]) {
+			reached[id] = true
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range adj[id] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	for i := range wf.Nodes {
+		node := &wf.Nodes[i]
+		if nodes[node.ID] == node && !reached[node.ID] {
+			add(ValidationIssue{Code: IssueUnreachable, NodeID: node.ID, Message: fmt.Sprintf("node %q is unreachable from any start node", node.ID)})
+		}
+	}
+
+	// Template references in prompts and sub-workflow inputs must point at upstream nodes
+	for i := range wf.Nodes {
+		node := &wf.Nodes[i]
+		if nodes[node.ID] != node {
+			continue
+		}
+		templateMsgs := validateTemplates(wf, node, nodes, adj)
+		if tool, isTool := tools[node.ID]; isTool {
+			// A tool node's templates refer to the tool's arguments instead of the workflow's inputs
+			inner, _ := ToolNodeWorkflow(&wf, tool)
+			innerNode := &inner.Nodes[0]
+			templateMsgs = validateTemplates(*inner, innerNode, map[string]*Node{node.ID: innerNode}, nil)
+		}
+		for _, msg := range templateMsgs {
+			add(ValidationIssue{Code: IssueTemplate, NodeID: node.ID, Message: fmt.Sprintf("node %q: %s", node.ID, msg)})
+		}
+		msgs := append(validateSubWorkflow(wf, node, nodes, adj), validateMap(wf, node, nodes, adj, registry)...)
+		for _, msg := range append(msgs, validateTools(wf, node, nodes)...) {
+			add(ValidationIssue{Code: IssueInvalidData, NodeID: node.ID, Message: fmt.Sprintf("node %q: %s", node.ID, msg)})
+		}
+	}
+
+	for _, msg := range validateIO(wf, nodes) {
+		add(ValidationIssue{Code: IssueInputOutput, Message: msg})
+	}
+	for _, msg := range validateAggregators(wf) {
+		add(ValidationIssue{Code: IssueAggregator, Message: msg})
+	}
+
+	// Cycles are only allowed when they pass through a LOOP node
+	for _, cycle := range unintendedCycles(nodes, adj) {
+		add(ValidationIssue{Code: IssueCycle, NodeIDs: cycle, Message: fmt.Sprintf("nodes %s form a cycle without a LOOP node", strings.Join(cycle, ", "))})
+	}
+
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// isTriggered mirrors the rule ExecuteBSP uses to send the initial trigger message
+func isTriggered(node *Node, inDegree int) bool {
+	return node.Type == NodeTypeStart || (inDegree == 0 && node.Type != NodeTypeResult && node.Type != NodeTypeEnd)
+}
+
+// validateNodeData checks type-specific settings in node data that a schema can't express
+func validateNodeData(node *Node) []string {
+	var msgs []string
+	if policy, err := DecodeRetryPolicy(node.Data["retry"]); err != nil {
+		msgs = append(msgs, fmt.Sprintf("retry: %v", err))
+	} else if policy != nil {
+		for _, msg := range policy.validate() {
+			msgs = append(msgs, "retry: "+msg)
+		}
+	}
+	if name, ok := node.Data["combiner"].(string); ok && name != "" {
+		if _, known := lookupCombiner(name); !known {
+			msgs = append(msgs, fmt.Sprintf("combiner: unknown combiner %q", name))
+		}
+	}
+	switch node.Type {
+	case NodeTypeStart:
+		// START nodes used to call the model with their prompt, they now only forward their input
+		if prompt, _ := node.Data["prompt"].(string); strings.TrimSpace(prompt) != "" {
+			msgs = append(msgs, "prompt: START nodes no longer call the model, move the prompt to an LLM node after it")
+		}
+	case NodeTypeLoop:
+		cond, err := DecodeCondition(node.Data["exit_condition"])
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("exit_condition: %v", err))
+		} else if msg := validateCondition(cond); msg != "" {
+			msgs = append(msgs, "exit_condition: "+msg)
+		}
+	}
+	return msgs
+}
+
+// templateFields are the node data fields interpreted as templates
+var templateFields = []string{"prompt", "system_prompt", "session"}
+
+// validateTemplates parses the template fields of a node and checks that every input it
+// references is declared and every node it references exists and can reach it
+func validateTemplates(wf Workflow, node *Node, nodes map[string]*Node, adj map[string][]string) []string {
+	var msgs []string
+	for _, field := range templateFields {
+		text, ok := node.Data[field].(string)
+		if !ok {
+			continue
+		}
+		tmpl, err := ParseTemplate(text)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", field, err))
+			continue
+		}
+		for _, ref := range tmpl.Refs() {
+			if msg := checkRef(wf, nodes, ref); msg != "" {
+				msgs = append(msgs, fmt.Sprintf("%s: %s", field, msg))
+			} else if ref.Namespace == TemplateNodes && !reaches(adj, ref.Key, node.ID) {
+				msgs = append(msgs, fmt.Sprintf("%s: {{%s}} references node %q, which is not upstream of this node", field, ref, ref.Key))
+			}
+		}
+	}
+	return msgs
+}
+
+// validateExpression checks an expression in node settings, such as a sub-workflow input mapping.
+// Node references must point at nodes upstream of node.
+func validateExpression(wf Workflow, node *Node, nodes map[string]*Node, adj map[string][]string, expr string) string {
+	part, err := parseExpression(expr)
+	if err != nil {
+		return err.Error()
+	}
+	if msg := checkRef(wf, nodes, *part.ref); msg != "" {
+		return msg
+	}
+	if part.ref.Namespace == TemplateNodes && !reaches(adj, part.ref.Key, node.ID) {
+		return fmt.Sprintf("references node %q, which is not upstream of this node", part.ref.Key)
+	}
+	return ""
+}
+
+// reaches reports whether there is a path of at least one edge from one node to another
+func reaches(adj map[string][]string, from, to string) bool {
+	seen := map[string]bool{}
+	queue := append([]string(nil), adj[from]...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, adj[id]...)
+	}
+	return false
+}
+
+// validateCondition checks a condition's operator and pattern without evaluating it
+func validateCondition(c *Condition) string {
+	if c == nil {
+		return ""
+	}
+	switch c.Operator {
+	case OpEquals, OpNotEquals, OpContains, OpNotContains, OpExists, OpElse:
+	case OpGreater, OpGreaterEq, OpLess, OpLessEq:
+		if _, ok := toFloat(c.Value); !ok {
+			return fmt.Sprintf("operator %q needs a numeric value", c.Operator)
+		}
+	case OpMatches:
+		if _, err := regexp.Compile(stringify(c.Value)); err != nil {
+			return fmt.Sprintf("invalid pattern: %v", err)
+		}
+	default:
+		return fmt.Sprintf("unknown condition operator %q", c.Operator)
+	}
+	return ""
+}
+
+// unintendedCycles returns the strongly connected components that contain a cycle
+// once LOOP nodes are removed from the graph. Each component is sorted.
+func unintendedCycles(nodes map[string]*Node, adj map[string][]string) [][]string {
+	ids := make([]string, 0, len(nodes))
+	for id, node := range nodes {
+		if node.Type != NodeTypeLoop {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	// Tarjan's strongly connected components algorithm
+	var (
+		index   = 0
+		indices = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  [][]string
+		visit   func(id string)
+	)
+	visit = func(id string) {
+		indices[id] = index
+		lowlink[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		selfLoop := false
+		for _, next := range adj[id] {
+			if nodes[next].Type == NodeTypeLoop {
+				continue
+			}
+			if next == id {
+				selfLoop = true
+			}
+			if _, seen := indices[next]; !seen {
+				visit(next)
+				lowlink[id] = min(lowlink[id], lowlink[next])
+			} else if onStack[next] {
+				lowlink[id] = min(lowlink[id], indices[next])
+			}
+		}
+
+		if lowlink[id] == indices[id] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == id {
+					break
+				}
+			}
+			if len(component) > 1 || selfLoop {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if _, seen := indices[id]; !seen {
+			visit(id)
+		}
+	}
+	return cycles
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// issues returns the problems Validate finds in wf, checked without a node registry
+func issues(t *testing.T, wf Workflow) []ValidationIssue {
+	t.Helper()
+	err := Validate(wf, nil)
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	return verr.Issues
+}
+
+// hasIssue reports whether one of the issues has the given code and node
+func hasIssue(list []ValidationIssue, code, nodeID string) bool {
+	for _, issue := range list {
+		if issue.Code == code && issue.NodeID == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateReportsStartNodeWithPrompt(t *testing.T) {
+	wf := Workflow{
+		ID:    "wf",
+		Nodes: []Node{node("start", NodeTypeStart, map[string]interface{}{"prompt": "Summarize the news"}), node("a", NodeTypeTask, nil)},
+		Edges: []Edge{edge("start", "a")},
+	}
+	if got := issues(t, wf); !hasIssue(got, IssueInvalidData, "start") {
+		t.Errorf("expected an invalid_data issue for the start node's prompt, got %+v", got)
+	}
+
+	wf.Nodes[0].Data["prompt"] = "  "
+	if got := issues(t, wf); len(got) != 0 {
+		t.Errorf("expected a blank prompt to be accepted, got %+v", got)
+	}
+}
+
+func TestValidateAcceptsValidWorkflow(t *testing.T) {
+	loopExit := edge("loop", "end")
+	loopExit.SourceHandle = LoopExitHandle
+	wf := Workflow{
+		ID: "wf",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("loop", NodeTypeLoop, nil),
+			node("body", NodeTypeTask, nil),
+			node("end", NodeTypeEnd, nil),
+		},
+		Edges: []Edge{edge("start", "loop"), edge("loop", "body"), edge("body", "loop"), loopExit},
+	}
+	if err := Validate(wf, nil); err != nil {
+		t.Errorf("expected a cycle through a LOOP node to be valid, got %v", err)
+	}
+}
+
+func TestValidateReportsAllIssues(t *testing.T) {
+	gt := edge("a", "b")
+	gt.Condition = &Condition{Field: "score", Operator: OpGreater, Value: "high"}
+	wf := Workflow{
+		ID: "wf",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("", NodeTypeTask, nil),
+			node("a", NodeTypeTask, nil),
+			node("a", NodeTypeTask, nil),
+			node("b", NodeTypeTask, nil),
+			node("c", NodeTypeTask, nil),
+			node("orphan", NodeTypeResult, nil),
+		},
+		Edges: []Edge{edge("start", "a"), gt, edge("b", "c"), edge("c", "b"), edge("a", "missing")},
+	}
+
+	got := issues(t, wf)
+	want := []struct{ code, node string }{
+		{IssueMissingID, ""},
+		{IssueDuplicateID, "a"},
+		{IssueUnreachable, "orphan"},
+	}
+	for _, w := range want {
+		if !hasIssue(got, w.code, w.node) {
+			t.Errorf("expected a %s issue for node %q, got %+v", w.code, w.node, got)
+		}
+	}
+
+	codes := map[string]ValidationIssue{}
+	for _, issue := range got {
+		codes[issue.Code] = issue
+	}
+	if issue := codes[IssueDanglingEdge]; issue.EdgeID != "a-missing" {
+		t.Errorf("expected the edge to a missing node to be reported, got %+v", got)
+	}
+	if issue := codes[IssueInvalidEdge]; issue.EdgeID != "a-b" {
+		t.Errorf("expected the non-numeric gt condition to be reported, got %+v", got)
+	}
+	if issue := codes[IssueCycle]; len(issue.NodeIDs) != 2 || issue.NodeIDs[0] != "b" || issue.NodeIDs[1] != "c" {
+		t.Errorf("expected the cycle b, c to be reported, got %+v", got)
+	}
+}
+
+func TestValidateChecksNodeTypesAgainstRegistry(t *testing.T) {
+	registry := NewRegistry()
+	newVertex := func(node Node) (Vertex, error) { return &funcVertex{compute: forward}, nil }
+	registry.MustRegister(NodeSpec{Type: NodeTypeStart, Schema: &Schema{Type: "object"}, New: newVertex})
+	registry.MustRegister(NodeSpec{
+		Type:   NodeTypeLLM,
+		Schema: &Schema{Type: "object", Properties: map[string]*Schema{"prompt": {Type: "string"}}, Required: []string{"prompt"}},
+		New:    newVertex,
+	})
+	wf := Workflow{
+		ID: "wf",
+		Nodes: []Node{
+			node("start", NodeTypeStart, nil),
+			node("llm", NodeTypeLLM, nil),
+			node("typo", "LMM", nil),
+			node("bad", NodeTypeLLM, map[string]interface{}{"prompt": 42}),
+		},
+		Edges: []Edge{edge("start", "llm"), edge("start", "typo"), edge("start", "bad")},
+	}
+
+	var verr *ValidationError
+	if !errors.As(Validate(wf, registry), &verr) {
+		t.Fatal("expected a validation error")
+	}
+	for _, w := range []struct{ code, node string }{
+		{IssueMissingData, "llm"},
+		{IssueUnknownType, "typo"},
+		{IssueInvalidData, "bad"},
+	} {
+		if !hasIssue(verr.Issues, w.code, w.node) {
+			t.Errorf("expected a %s issue for node %q, got %+v", w.code, w.node, verr.Issues)
+		}
+	}
+}
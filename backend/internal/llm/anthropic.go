@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultAnthropicBaseURL is used when an Anthropic client has no base URL
+	DefaultAnthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+	// anthropicMaxTokens is sent when no limit is configured, since the API requires one
+	anthropicMaxTokens = 1024
+)
+
+// AnthropicClient implements Client for the Anthropic Messages API
+type AnthropicClient struct {
+	http      *http.Client
+	baseURL   string
+	apiKey    string
+	model     string
+	maxTokens int
+}
+
+// NewAnthropicClient creates a new Anthropic client. An empty baseURL uses DefaultAnthropicBaseURL.
+func NewAnthropicClient(apiKey, model, baseURL string, httpClient *http.Client) *AnthropicClient {
+	if baseURL == "" {
+		baseURL = DefaultAnthropicBaseURL
+	}
+	return &AnthropicClient{
+		http:    httpClient,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+// anthropicMessage holds its content as text or, for tool use and results, as a list of blocks
+type anthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// tool_use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result blocks
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []anthropicBlock `json:"content"`
+}
+
+// WithMaxTokens sets the token limit of requests that don't set their own
+func (c *AnthropicClient) WithMaxTokens(maxTokens int) *AnthropicClient {
+	c.maxTokens = maxTokens
+	return c
+}
+
+// Generate generates text based on the request. The API has no seed, so Request.Seed is ignored.
+func (c *AnthropicClient) Generate(ctx context.Context, r Request) (string, error) {
+	resp, err := c.GenerateWithTools(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	if resp.Content == "" && len(resp.ToolCalls) == 0 {
+		return "", fmt.Errorf("no text returned from Anthropic")
+	}
+	return resp.Content, nil
+}
+
+// GenerateWithTools generates a reply that may call the request's tools
+func (c *AnthropicClient) GenerateWithTools(ctx context.Context, r Request) (*Response, error) {
+	model := firstNonEmpty(r.Model, c.model)
+	if model == "" {
+		return nil, fmt.Errorf("no model set for Anthropic request")
+	}
+	system, turns := r.systemPrompt()
+	if len(r.ResponseSchema) > 0 {
+		// The Messages API has no JSON mode, so the schema goes into the system prompt
+		system = strings.TrimSpace(system + "\n\n" + jsonInstruction(r.ResponseSchema))
+	}
+	messages := anthropicMessages(turns)
+	req := anthropicRequest{
+		Model:         model,
+		MaxTokens:     firstPositive(r.MaxTokens, c.maxTokens, anthropicMaxTokens),
+		System:        system,
+		Messages:      messages,
+		Temperature:   r.Temperature,
+		TopP:          r.TopP,
+		StopSequences: r.Stop,
+	}
+	for _, tool := range r.Tools {
+		req.Tools = append(req.Tools, anthropicTool{Name: tool.Name, Description: tool.Description, InputSchema: tool.Parameters})
+	}
+	headers := map[string]string{
+		"x-api-key":         c.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+
+	var resp anthropicResponse
+	if err := postJSON(ctx, c.http, "Anthropic", c.baseURL+"/v1/messages", headers, req, &resp); err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	result := &Response{}
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, ToolCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
+		}
+	}
+	result.Content = text.String()
+	return result, nil
+}
+
+// anthropicMessages converts a conversation without system messages. Tool calls become tool_use
+// blocks, and the results of consecutive tool messages are combined into one user message as
+// the API requires.
+func anthropicMessages(turns []ChatMessage) []anthropicMessage {
+	messages := make([]anthropicMessage, 0, len(turns))
+	for _, msg := range turns {
+		switch {
+		case msg.Role == RoleTool:
+			block := anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}
+			if n := len(messages); n > 0 && messages[n-1].Role == RoleUser {
+				if blocks, ok := messages[n-1].Content.([]anthropicBlock); ok {
+					messages[n-1].Content = append(blocks, block)
+					continue
+				}
+			}
+			messages = append(messages, anthropicMessage{Role: RoleUser, Content: []anthropicBlock{block}})
+		case len(msg.ToolCalls) > 0:
+			var blocks []anthropicBlock
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: toolArguments(call)})
+			}
+			messages = append(messages, anthropicMessage{Role: msg.Role, Content: blocks})
+		default:
+			messages = append(messages, anthropicMessage{Role: msg.Role, Content: msg.Content})
+		}
+	}
+	return messages
+}
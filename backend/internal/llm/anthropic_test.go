@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAnthropicRequest(t *testing.T) {
+	srv, rec := fakeProvider(t, http.StatusOK, `{"content":[{"type":"text","text":"Hello "},{"type":"text","text":"there"}]}`)
+	client := NewAnthropicClient("secret", "claude-default", srv.URL, nil).WithMaxTokens(256)
+
+	text, err := client.Generate(context.Background(), Request{
+		System:      "Be brief.",
+		Prompt:      "Hi",
+		Temperature: floatPtr(0),
+		Stop:        []string{"END"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Hello there" {
+		t.Errorf("expected concatenated text blocks, got %q", text)
+	}
+
+	if rec.Path != "/v1/messages" {
+		t.Errorf("unexpected path %s", rec.Path)
+	}
+	if got := rec.Header.Get("x-api-key"); got != "secret" {
+		t.Errorf("unexpected x-api-key %q", got)
+	}
+	if got := rec.Header.Get("anthropic-version"); got != anthropicVersion {
+		t.Errorf("unexpected anthropic-version %q", got)
+	}
+	want := map[string]interface{}{
+		"model":          "claude-default",
+		"max_tokens":     256.0,
+		"system":         "Be brief.",
+		"messages":       []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+		"temperature":    0.0,
+		"stop_sequences": []interface{}{"END"},
+	}
+	if !reflect.DeepEqual(rec.Body, want) {
+		t.Errorf("unexpected request body\n got: %v\nwant: %v", rec.Body, want)
+	}
+}
+
+func TestAnthropicDefaultMaxTokens(t *testing.T) {
+	srv, rec := fakeProvider(t, http.StatusOK, `{"content":[{"type":"text","text":"ok"}]}`)
+	client := NewAnthropicClient("k", "", srv.URL, nil)
+
+	if _, err := client.Generate(context.Background(), Request{Model: "claude-node", Prompt: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Body["model"] != "claude-node" {
+		t.Errorf("expected the request model to win, got %v", rec.Body["model"])
+	}
+	if rec.Body["max_tokens"] != float64(anthropicMaxTokens) {
+		t.Errorf("expected max_tokens %d, got %v", anthropicMaxTokens, rec.Body["max_tokens"])
+	}
+}
+
+func TestAnthropicToolCalls(t *testing.T) {
+	srv, rec := fakeProvider(t, http.StatusOK,
+		`{"content":[{"type":"tool_use","id":"tu_1","name":"lookup","input":{"q":"go"}}]}`)
+	client := NewAnthropicClient("k", "claude", srv.URL, nil)
+
+	resp, err := client.GenerateWithTools(context.Background(), Request{
+		Messages: []ChatMessage{
+			{Role: RoleUser, Content: "find go"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "a", Name: "lookup", Arguments: `{"q":"a"}`}, {ID: "b", Name: "lookup", Arguments: `{"q":"b"}`}}},
+			{Role: RoleTool, ToolCallID: "a", Name: "lookup", Content: "A"},
+			{Role: RoleTool, ToolCallID: "b", Name: "lookup", Content: "B"},
+		},
+		Tools: []Tool{{Name: "lookup", Parameters: []byte(`{"type":"object"}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ToolCall{{ID: "tu_1", Name: "lookup", Arguments: `{"q":"go"}`}}
+	if !reflect.DeepEqual(resp.ToolCalls, want) {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+
+	messages := rec.Body["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("expected tool results merged into one message, got %d messages", len(messages))
+	}
+	results := messages[2].(map[string]interface{})["content"].([]interface{})
+	if len(results) != 2 || results[1].(map[string]interface{})["tool_use_id"] != "b" {
+		t.Errorf("unexpected tool results %v", results)
+	}
+	tools := rec.Body["tools"].([]interface{})
+	if tools[0].(map[string]interface{})["input_schema"] == nil {
+		t.Errorf("expected the tool schema as input_schema, got %v", tools[0])
+	}
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	srv, _ := fakeProvider(t, http.StatusTooManyRequests, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	client := NewAnthropicClient("k", "claude", srv.URL, nil)
+
+	_, err := client.Generate(context.Background(), Request{Prompt: "Hi"})
+	requireStatus(t, err, http.StatusTooManyRequests)
+	if got := err.Error(); got != "Anthropic API error: 429 Too Many Requests: slow down" {
+		t.Errorf("unexpected error message %q", got)
+	}
+
+	if _, err := NewAnthropicClient("k", "", srv.URL, nil).Generate(context.Background(), Request{Prompt: "Hi"}); err == nil {
+		t.Error("expected an error without a model")
+	}
+}
@@ -0,0 +1,82 @@
+package llm
+
+import "unicode/utf8"
+
+// Roles of chat messages
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ChatMessage is one role-tagged turn of a conversation
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ToolCalls are the calls an assistant message requested
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID and Name identify the call a RoleTool message answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// Conversation returns the messages of the request followed by Prompt as a user message
+// when it is set
+func (r Request) Conversation() []ChatMessage {
+	msgs := append([]ChatMessage(nil), r.Messages...)
+	if r.Prompt != "" {
+		msgs = append(msgs, ChatMessage{Role: RoleUser, Content: r.Prompt})
+	}
+	return msgs
+}
+
+// systemPrompt joins the request's system prompt and any system messages of the conversation,
+// for APIs that take the system prompt separately
+func (r Request) systemPrompt() (string, []ChatMessage) {
+	system := r.System
+	var turns []ChatMessage
+	for _, msg := range r.Conversation() {
+		if msg.Role != RoleSystem {
+			turns = append(turns, msg)
+			continue
+		}
+		if system != "" {
+			system += "\n\n"
+		}
+		system += msg.Content
+	}
+	return system, turns
+}
+
+// EstimateTokens approximates the number of tokens of a text at four characters per token
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// messageOverhead approximates the tokens a message costs beyond its content
+const messageOverhead = 4
+
+// TrimMessages drops the oldest non-system messages until the estimated size of msgs fits in
+// budget tokens. Assistant messages left at the start of the conversation are dropped too,
+// since providers expect it to open with a user message. The last message is always kept.
+func TrimMessages(msgs []ChatMessage, budget int) []ChatMessage {
+	total := 0
+	for _, msg := range msgs {
+		total += EstimateTokens(msg.Content) + messageOverhead
+	}
+
+	trimmed := make([]ChatMessage, 0, len(msgs))
+	opened := false
+	for i, msg := range msgs {
+		last := i == len(msgs)-1
+		if msg.Role != RoleSystem && !last && (total > budget || (!opened && msg.Role == RoleAssistant)) {
+			total -= EstimateTokens(msg.Content) + messageOverhead
+			continue
+		}
+		if msg.Role != RoleSystem {
+			opened = true
+		}
+		trimmed = append(trimmed, msg)
+	}
+	return trimmed
+}
@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// turn returns a message whose content costs 10 tokens, 14 with the message overhead
+func turn(role, name string) ChatMessage {
+	return ChatMessage{Role: role, Content: name + strings.Repeat(".", 40-len(name))}
+}
+
+func names(msgs []ChatMessage) []string {
+	out := make([]string, len(msgs))
+	for i, msg := range msgs {
+		out[i] = strings.TrimRight(msg.Content, ".")
+	}
+	return out
+}
+
+func TestTrimMessages(t *testing.T) {
+	conversation := []ChatMessage{
+		turn(RoleSystem, "system"),
+		turn(RoleUser, "user1"),
+		turn(RoleAssistant, "assistant1"),
+		turn(RoleUser, "user2"),
+		turn(RoleAssistant, "assistant2"),
+		turn(RoleUser, "user3"),
+	}
+	tests := []struct {
+		budget int
+		want   []string
+	}{
+		// 6 messages of 14 tokens fit
+		{84, []string{"system", "user1", "assistant1", "user2", "assistant2", "user3"}},
+		// Dropping user1 is enough, but the conversation may not open with assistant1
+		{75, []string{"system", "user2", "assistant2", "user3"}},
+		{56, []string{"system", "user2", "assistant2", "user3"}},
+		{55, []string{"system", "user3"}},
+		// The system prompt and the last message are kept even over budget
+		{0, []string{"system", "user3"}},
+	}
+	for _, tt := range tests {
+		if got := names(TrimMessages(conversation, tt.budget)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("budget %d: expected %v, got %v", tt.budget, tt.want, got)
+		}
+	}
+
+	if got := names(conversation); len(got) != 6 || got[1] != "user1" {
+		t.Errorf("expected the input not to be modified, got %v", got)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := map[string]int{"": 0, "abc": 1, "abcd": 1, "abcde": 2, "héllo wörld": 3}
+	for text, want := range tests {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("%q: expected %d tokens, got %d", text, want, got)
+		}
+	}
+}
+
+func TestConversationAppendsPrompt(t *testing.T) {
+	req := Request{Messages: []ChatMessage{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}}, Prompt: "how are you?"}
+	got := req.Conversation()
+	if len(got) != 3 || got[2].Role != RoleUser || got[2].Content != "how are you?" {
+		t.Errorf("expected the prompt as the last user turn, got %+v", got)
+	}
+	if len(req.Messages) != 2 {
+		t.Errorf("expected the request's messages not to change, got %+v", req.Messages)
+	}
+}
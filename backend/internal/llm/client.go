@@ -2,20 +2,46 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // Client defines the interface for LLM interactions
 type Client interface {
-	Generate(ctx context.Context, prompt string) (string, error)
+	Generate(ctx context.Context, req Request) (string, error)
+}
+
+// Request is a single generation. Unset fields use the client's defaults, which come from
+// the server's LLM configuration.
+type Request struct {
+	Model  string
+	System string
+	// Messages is the conversation so far, oldest first
+	Messages []ChatMessage
+	// Prompt, if set, is sent as a final user message after Messages
+	Prompt string
+	// Temperature, TopP and Seed are pointers so that 0 can be told apart from unset
+	Temperature *float64
+	TopP        *float64
+	MaxTokens   int
+	Stop        []string
+	Seed        *int
+	// ResponseSchema, if set, is a JSON Schema the reply must follow. Providers with native
+	// structured output enforce it; the others are instructed to reply with matching JSON.
+	ResponseSchema json.RawMessage
+	// Tools are offered to the model by GenerateWithTools
+	Tools []Tool
 }
 
 // OpenAIClient implements Client for OpenAI
 type OpenAIClient struct {
-	client *openai.Client
-	model  string
+	client    *openai.Client
+	model     string
+	maxTokens int
 }
 
 // NewOpenAIClient creates a new OpenAI client
@@ -26,35 +52,144 @@ func NewOpenAIClient(apiKey string, model string) *OpenAIClient {
 	}
 }
 
-// Generate generates text based on the prompt
-func (c *OpenAIClient) Generate(ctx context.Context, prompt string) (string, error) {
-	resp, err := c.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: c.model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
+// NewOpenAICompatibleClient creates a client for a server that implements the OpenAI chat
+// completions API at baseURL, such as vLLM, llama.cpp or Ollama. apiKey may be empty for
+// servers without authentication, and a nil httpClient uses the default one.
+func NewOpenAICompatibleClient(apiKey, model, baseURL string, httpClient *http.Client) *OpenAIClient {
+	cfg := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	if httpClient != nil {
+		cfg.HTTPClient = httpClient
+	}
+	return &OpenAIClient{
+		client: openai.NewClientWithConfig(cfg),
+		model:  model,
+	}
+}
+
+// WithMaxTokens sets the token limit of requests that don't set their own
+func (c *OpenAIClient) WithMaxTokens(maxTokens int) *OpenAIClient {
+	c.maxTokens = maxTokens
+	return c
+}
+
+// Generate generates text based on the request
+func (c *OpenAIClient) Generate(ctx context.Context, req Request) (string, error) {
+	resp, err := c.GenerateWithTools(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// GenerateWithTools generates a reply that may call the request's tools
+func (c *OpenAIClient) GenerateWithTools(ctx context.Context, req Request) (*Response, error) {
+	model := firstNonEmpty(req.Model, c.model)
+	if model == "" {
+		return nil, fmt.Errorf("no model set for OpenAI request")
+	}
+
+	conversation := req.Conversation()
+	messages := make([]openai.ChatCompletionMessage, 0, len(conversation)+1)
+	if req.System != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: req.System})
+	}
+	for _, msg := range conversation {
+		m := openai.ChatCompletionMessage{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID}
+		for _, call := range msg.ToolCalls {
+			m.ToolCalls = append(m.ToolCalls, openai.ToolCall{
+				ID:       call.ID,
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: call.Name, Arguments: string(toolArguments(call))},
+			})
+		}
+		messages = append(messages, m)
+	}
+
+	chatReq := openai.ChatCompletionRequest{
+		Model:     model,
+		Messages:  messages,
+		MaxTokens: firstPositive(req.MaxTokens, c.maxTokens),
+		Stop:      req.Stop,
+		Seed:      req.Seed,
+	}
+	if req.Temperature != nil {
+		chatReq.Temperature = openAIFloat(*req.Temperature)
+	}
+	if req.TopP != nil {
+		chatReq.TopP = openAIFloat(*req.TopP)
+	}
+	if len(req.ResponseSchema) > 0 {
+		chatReq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type:       openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{Name: "response", Schema: req.ResponseSchema},
+		}
+	}
+
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
 			},
-		},
-	)
+		})
+	}
 
+	resp, err := c.client.CreateChatCompletion(ctx, chatReq)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI API error: %w", err)
+		return nil, openAIError(err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned from OpenAI")
+		return nil, fmt.Errorf("no choices returned from OpenAI")
+	}
+
+	reply := resp.Choices[0].Message
+	result := &Response{Content: reply.Content}
+	for _, call := range reply.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
 	}
+	return result, nil
+}
 
-	return resp.Choices[0].Message.Content, nil
+// openAIFloat converts a sampling parameter. The OpenAI client omits zero values, so an
+// explicit 0 is sent as the smallest positive float instead.
+func openAIFloat(v float64) float32 {
+	if v == 0 {
+		return math.SmallestNonzeroFloat32
+	}
+	return float32(v)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
 }
 
 // MockClient for testing or when no API key is provided
 type MockClient struct{}
 
-func (c *MockClient) Generate(ctx context.Context, prompt string) (string, error) {
-	return fmt.Sprintf("[MOCK] Response to: %s", prompt), nil
+func (c *MockClient) Generate(ctx context.Context, req Request) (string, error) {
+	conversation := req.Conversation()
+	if len(conversation) == 0 {
+		return "[MOCK] Response to: ", nil
+	}
+	return fmt.Sprintf("[MOCK] Response to: %s", conversation[len(conversation)-1].Content), nil
 }
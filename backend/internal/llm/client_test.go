@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const openAIReply = `{"choices":[{"index":0,"message":{"role":"assistant","content":"hi"}}]}`
+
+func TestOpenAICompatibleRequest(t *testing.T) {
+	srv, rec := fakeProvider(t, http.StatusOK, openAIReply)
+	client := NewOpenAICompatibleClient("secret", "local-model", srv.URL+"/v1", nil).WithMaxTokens(64)
+
+	seed := 3
+	text, err := client.Generate(context.Background(), Request{
+		System:      "Be brief.",
+		Prompt:      "Hello",
+		Temperature: floatPtr(0),
+		Stop:        []string{"\n"},
+		Seed:        &seed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hi" {
+		t.Errorf("unexpected reply %q", text)
+	}
+
+	if rec.Path != "/v1/chat/completions" {
+		t.Errorf("unexpected path %s", rec.Path)
+	}
+	if got := rec.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("unexpected Authorization %q", got)
+	}
+	if rec.Body["model"] != "local-model" || rec.Body["max_tokens"] != 64.0 || rec.Body["seed"] != 3.0 {
+		t.Errorf("unexpected settings in %v", rec.Body)
+	}
+	if temp, _ := rec.Body["temperature"].(float64); temp <= 0 || temp > 1e-6 {
+		t.Errorf("expected an explicit 0 temperature to be sent as a tiny positive value, got %v", rec.Body["temperature"])
+	}
+	wantMessages := []interface{}{
+		map[string]interface{}{"role": "system", "content": "Be brief."},
+		map[string]interface{}{"role": "user", "content": "Hello"},
+	}
+	if !reflect.DeepEqual(rec.Body["messages"], wantMessages) {
+		t.Errorf("unexpected messages %v", rec.Body["messages"])
+	}
+}
+
+func TestOpenAICompatibleStructuredOutput(t *testing.T) {
+	srv, rec := fakeProvider(t, http.StatusOK, openAIReply)
+	client := NewOpenAICompatibleClient("", "m", srv.URL, nil)
+
+	if _, err := client.Generate(context.Background(), Request{Prompt: "Hi", ResponseSchema: []byte(`{"type":"object"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	format, _ := rec.Body["response_format"].(map[string]interface{})
+	if format["type"] != "json_schema" {
+		t.Errorf("expected a json_schema response format, got %v", rec.Body["response_format"])
+	}
+}
+
+func TestOpenAICompatibleToolCalls(t *testing.T) {
+	srv, rec := fakeProvider(t, http.StatusOK, `{"choices":[{"message":{"role":"assistant","tool_calls":[
+		{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":\"go\"}"}}]}}]}`)
+	client := NewOpenAICompatibleClient("", "m", srv.URL, nil)
+
+	resp, err := client.GenerateWithTools(context.Background(), Request{
+		Prompt: "find go",
+		Tools:  []Tool{{Name: "lookup", Description: "Search", Parameters: []byte(`{"type":"object"}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ToolCall{{ID: "call_1", Name: "lookup", Arguments: `{"q":"go"}`}}
+	if !reflect.DeepEqual(resp.ToolCalls, want) {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+	tools, _ := rec.Body["tools"].([]interface{})
+	if len(tools) != 1 {
+		t.Fatalf("expected 1 tool in the request, got %v", rec.Body["tools"])
+	}
+}
+
+func TestOpenAICompatibleErrors(t *testing.T) {
+	srv, _ := fakeProvider(t, http.StatusNotFound, `{"error":{"message":"model not found","type":"invalid_request_error"}}`)
+	_, err := NewOpenAICompatibleClient("", "missing", srv.URL, nil).Generate(context.Background(), Request{Prompt: "Hi"})
+	requireStatus(t, err, http.StatusNotFound)
+
+	unavailable, _ := fakeProvider(t, http.StatusServiceUnavailable, `upstream down`)
+	_, err = NewOpenAICompatibleClient("", "m", unavailable.URL, nil).Generate(context.Background(), Request{Prompt: "Hi"})
+	requireStatus(t, err, http.StatusServiceUnavailable)
+}
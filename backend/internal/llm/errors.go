@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// APIError is a failed request to an LLM provider
+type APIError struct {
+	Provider string
+	// StatusCode is the HTTP status of the response, or 0 if none was received
+	StatusCode int
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error: %v", e.Provider, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus lets the engine classify the error for retries
+func (e *APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// openAIError wraps an error returned by the OpenAI client together with its HTTP status
+func openAIError(err error) error {
+	apiErr := &APIError{Provider: "OpenAI", Err: err}
+	var respErr *openai.APIError
+	var reqErr *openai.RequestError
+	if errors.As(err, &respErr) {
+		apiErr.StatusCode = respErr.HTTPStatusCode
+	} else if errors.As(err, &reqErr) {
+		apiErr.StatusCode = reqErr.HTTPStatusCode
+	}
+	return apiErr
+}
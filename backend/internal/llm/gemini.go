@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGeminiBaseURL is used when a Gemini client has no base URL
+const DefaultGeminiBaseURL = "https://generativelanguage.googleapis.com"
+
+// GeminiClient implements Client for the Gemini generateContent API
+type GeminiClient struct {
+	http      *http.Client
+	baseURL   string
+	apiKey    string
+	model     string
+	maxTokens int
+}
+
+// NewGeminiClient creates a new Gemini client. An empty baseURL uses DefaultGeminiBaseURL.
+func NewGeminiClient(apiKey, model, baseURL string, httpClient *http.Client) *GeminiClient {
+	if baseURL == "" {
+		baseURL = DefaultGeminiBaseURL
+	}
+	return &GeminiClient{
+		http:    httpClient,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	// ResponseMIMEType and ResponseJSONSchema request structured output
+	ResponseMIMEType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+	Tools             []geminiTool           `json:"tools,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+}
+
+// WithMaxTokens sets the token limit of requests that don't set their own
+func (c *GeminiClient) WithMaxTokens(maxTokens int) *GeminiClient {
+	c.maxTokens = maxTokens
+	return c
+}
+
+// Generate generates text based on the request
+func (c *GeminiClient) Generate(ctx context.Context, r Request) (string, error) {
+	resp, err := c.GenerateWithTools(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// GenerateWithTools generates a reply that may call the request's tools
+func (c *GeminiClient) GenerateWithTools(ctx context.Context, r Request) (*Response, error) {
+	system, turns := r.systemPrompt()
+	req := geminiRequest{
+		Contents: geminiContents(turns),
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     r.Temperature,
+			TopP:            r.TopP,
+			MaxOutputTokens: firstPositive(r.MaxTokens, c.maxTokens),
+			StopSequences:   r.Stop,
+			Seed:            r.Seed,
+		},
+	}
+	if len(r.ResponseSchema) > 0 {
+		req.GenerationConfig.ResponseMIMEType = "application/json"
+		req.GenerationConfig.ResponseJSONSchema = r.ResponseSchema
+	}
+	if system != "" {
+		req.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+	if len(r.Tools) > 0 {
+		tool := geminiTool{}
+		for _, t := range r.Tools {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, geminiFunctionDeclaration{
+				Name:                 t.Name,
+				Description:          t.Description,
+				ParametersJSONSchema: t.Parameters,
+			})
+		}
+		req.Tools = []geminiTool{tool}
+	}
+	model := firstNonEmpty(r.Model, c.model)
+	if model == "" {
+		return nil, fmt.Errorf("no model set for Gemini request")
+	}
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:generateContent", c.baseURL, url.PathEscape(model))
+	headers := map[string]string{"x-goog-api-key": c.apiKey}
+
+	var resp geminiResponse
+	if err := postJSON(ctx, c.http, "Gemini", endpoint, headers, req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidates returned from Gemini")
+	}
+
+	var text strings.Builder
+	result := &Response{}
+	for i, part := range resp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+		if call := part.FunctionCall; call != nil {
+			// Older models don't number their calls
+			id := call.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", i)
+			}
+			result.ToolCalls = append(result.ToolCalls, ToolCall{ID: id, Name: call.Name, Arguments: string(call.Args)})
+		}
+	}
+	result.Content = text.String()
+	return result, nil
+}
+
+// geminiContents converts a conversation without system messages. Gemini calls the assistant
+// "model" and expects the results of consecutive tool calls in one user content.
+func geminiContents(turns []ChatMessage) []geminiContent {
+	contents := make([]geminiContent, 0, len(turns))
+	for _, msg := range turns {
+		switch {
+		case msg.Role == RoleTool:
+			part := geminiPart{FunctionResponse: &geminiFunctionResponse{
+				Name:     msg.Name,
+				Response: map[string]interface{}{"content": msg.Content},
+			}}
+			if n := len(contents); n > 0 && len(contents[n-1].Parts) > 0 && contents[n-1].Parts[0].FunctionResponse != nil {
+				contents[n-1].Parts = append(contents[n-1].Parts, part)
+				continue
+			}
+			contents = append(contents, geminiContent{Role: RoleUser, Parts: []geminiPart{part}})
+		case msg.Role == RoleAssistant:
+			content := geminiContent{Role: "model"}
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, geminiPart{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				content.Parts = append(content.Parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: call.Name, Args: toolArguments(call)}})
+			}
+			contents = append(contents, content)
+		default:
+			contents = append(contents, geminiContent{Role: msg.Role, Parts: []geminiPart{{Text: msg.Content}}})
+		}
+	}
+	return contents
+}
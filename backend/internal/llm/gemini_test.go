@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGeminiRequest(t *testing.T) {
+	srv, rec := fakeProvider(t, http.StatusOK, `{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"ok\":true}"}]}}]}`)
+	client := NewGeminiClient("secret", "gemini-default", srv.URL, nil).WithMaxTokens(100)
+
+	seed := 7
+	text, err := client.Generate(context.Background(), Request{
+		Model:          "gemini-node",
+		System:         "Reply in JSON.",
+		Messages:       []ChatMessage{{Role: RoleUser, Content: "Hi"}, {Role: RoleAssistant, Content: "Hello"}},
+		Prompt:         "Status?",
+		TopP:           floatPtr(0.5),
+		Seed:           &seed,
+		ResponseSchema: []byte(`{"type":"object"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != `{"ok":true}` {
+		t.Errorf("unexpected reply %q", text)
+	}
+
+	if rec.Path != "/v1beta/models/gemini-node:generateContent" {
+		t.Errorf("unexpected path %s", rec.Path)
+	}
+	if got := rec.Header.Get("x-goog-api-key"); got != "secret" {
+		t.Errorf("unexpected x-goog-api-key %q", got)
+	}
+	want := map[string]interface{}{
+		"contents": []interface{}{
+			map[string]interface{}{"role": "user", "parts": []interface{}{map[string]interface{}{"text": "Hi"}}},
+			map[string]interface{}{"role": "model", "parts": []interface{}{map[string]interface{}{"text": "Hello"}}},
+			map[string]interface{}{"role": "user", "parts": []interface{}{map[string]interface{}{"text": "Status?"}}},
+		},
+		"systemInstruction": map[string]interface{}{"parts": []interface{}{map[string]interface{}{"text": "Reply in JSON."}}},
+		"generationConfig": map[string]interface{}{
+			"topP":               0.5,
+			"maxOutputTokens":    100.0,
+			"seed":               7.0,
+			"responseMimeType":   "application/json",
+			"responseJsonSchema": map[string]interface{}{"type": "object"},
+		},
+	}
+	if !reflect.DeepEqual(rec.Body, want) {
+		t.Errorf("unexpected request body\n got: %v\nwant: %v", rec.Body, want)
+	}
+}
+
+func TestGeminiToolCalls(t *testing.T) {
+	srv, _ := fakeProvider(t, http.StatusOK,
+		`{"candidates":[{"content":{"parts":[{"functionCall":{"name":"lookup","args":{"q":"go"}}}]}}]}`)
+	client := NewGeminiClient("k", "gemini", srv.URL, nil)
+
+	resp, err := client.GenerateWithTools(context.Background(), Request{
+		Prompt: "find go",
+		Tools:  []Tool{{Name: "lookup", Parameters: []byte(`{"type":"object"}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ToolCall{{ID: "call_0", Name: "lookup", Arguments: `{"q":"go"}`}}
+	if !reflect.DeepEqual(resp.ToolCalls, want) {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+}
+
+func TestGeminiErrors(t *testing.T) {
+	srv, _ := fakeProvider(t, http.StatusBadRequest, `{"error":{"code":400,"message":"API key not valid"}}`)
+	_, err := NewGeminiClient("bad", "gemini", srv.URL, nil).Generate(context.Background(), Request{Prompt: "Hi"})
+	requireStatus(t, err, http.StatusBadRequest)
+
+	empty, _ := fakeProvider(t, http.StatusOK, `{"candidates":[]}`)
+	if _, err := NewGeminiClient("k", "gemini", empty.URL, nil).Generate(context.Background(), Request{Prompt: "Hi"}); err == nil {
+		t.Error("expected an error when no candidates are returned")
+	}
+}
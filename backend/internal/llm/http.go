@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody bounds how much of an error response is read into the error message
+const maxErrorBody = 4096
+
+// postJSON sends body as JSON to url and decodes the response into out. Failed requests are
+// returned as an *APIError carrying the HTTP status, or 0 if no response was received.
+func postJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", provider, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return &APIError{Provider: provider, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return &APIError{Provider: provider, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &APIError{Provider: provider, StatusCode: resp.StatusCode, Err: errors.New(errorMessage(resp.Status, data))}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &APIError{Provider: provider, StatusCode: resp.StatusCode, Err: fmt.Errorf("invalid response: %w", err)}
+	}
+	return nil
+}
+
+// errorMessage extracts error.message from a JSON error response, falling back to the raw body
+func errorMessage(status string, body []byte) string {
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error.Message != "" {
+		return status + ": " + parsed.Error.Message
+	}
+	if text := strings.TrimSpace(string(body)); text != "" {
+		return status + ": " + text
+	}
+	return status
+}
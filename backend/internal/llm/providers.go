@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"workflow-platform/internal/config"
+)
+
+// Built-in providers
+const (
+	ProviderOpenAI           = "openai"
+	ProviderAnthropic        = "anthropic"
+	ProviderGemini           = "gemini"
+	ProviderOpenAICompatible = "openai_compatible" // any server implementing the OpenAI API, selected by base URL
+	ProviderOllama           = "ollama"            // OpenAI-compatible, defaulting to a local Ollama server
+	ProviderMock             = "mock"
+)
+
+// DefaultOllamaBaseURL is used when an Ollama client has no base URL
+const DefaultOllamaBaseURL = "http://localhost:11434/v1"
+
+// providerKeyEnv names the environment variable holding each provider's API key. LLM_API_KEY
+// takes precedence for the configured default provider.
+var providerKeyEnv = map[string]string{
+	ProviderOpenAI:           "OPENAI_API_KEY",
+	ProviderAnthropic:        "ANTHROPIC_API_KEY",
+	ProviderGemini:           "GEMINI_API_KEY",
+	ProviderOpenAICompatible: "OPENAI_COMPATIBLE_API_KEY",
+}
+
+// ProviderConfig selects a provider and the settings its client is created with
+type ProviderConfig struct {
+	Provider string
+	APIKey   string
+	Model    string
+	// BaseURL overrides the provider's endpoint, e.g. to reach a proxy or a local server
+	BaseURL string
+	// MaxTokens limits requests that don't set their own limit
+	MaxTokens int
+	// HTTPClient is used for requests; nil uses the default client
+	HTTPClient *http.Client
+}
+
+// ProviderFactory creates a client for a provider
+type ProviderFactory func(cfg ProviderConfig) (Client, error)
+
+// Registry creates and caches LLM clients by provider. Providers are selected by name,
+// with unset settings falling back to the LLM configuration of the server. Only clients
+// using the server's API keys are cached.
+type Registry struct {
+	cfg       config.LLMConfig
+	keys      map[string]string
+	mu        sync.Mutex
+	factories map[string]ProviderFactory
+	clients   map[ProviderConfig]Client
+}
+
+// NewRegistry returns a registry with the built-in providers. API keys are read from the
+// configuration and the provider-specific environment variables.
+func NewRegistry(cfg config.LLMConfig) *Registry {
+	r := &Registry{
+		cfg:       cfg,
+		keys:      make(map[string]string),
+		factories: make(map[string]ProviderFactory),
+		clients:   make(map[ProviderConfig]Client),
+	}
+	for provider, env := range providerKeyEnv {
+		if key := os.Getenv(env); key != "" {
+			r.keys[provider] = key
+		}
+	}
+	if cfg.APIKey != "" {
+		r.keys[cfg.Provider] = cfg.APIKey
+	}
+
+	r.Register(ProviderOpenAI, func(c ProviderConfig) (Client, error) {
+		return NewOpenAICompatibleClient(c.APIKey, c.Model, c.BaseURL, c.HTTPClient).WithMaxTokens(c.MaxTokens), nil
+	})
+	r.Register(ProviderOpenAICompatible, func(c ProviderConfig) (Client, error) {
+		if c.BaseURL == "" {
+			return nil, fmt.Errorf("provider %s needs a base URL", ProviderOpenAICompatible)
+		}
+		return NewOpenAICompatibleClient(c.APIKey, c.Model, c.BaseURL, c.HTTPClient).WithMaxTokens(c.MaxTokens), nil
+	})
+	r.Register(ProviderOllama, func(c ProviderConfig) (Client, error) {
+		if c.BaseURL == "" {
+			c.BaseURL = DefaultOllamaBaseURL
+		}
+		return NewOpenAICompatibleClient(c.APIKey, c.Model, c.BaseURL, c.HTTPClient).WithMaxTokens(c.MaxTokens), nil
+	})
+	r.Register(ProviderAnthropic, func(c ProviderConfig) (Client, error) {
+		return NewAnthropicClient(c.APIKey, c.Model, c.BaseURL, c.HTTPClient).WithMaxTokens(c.MaxTokens), nil
+	})
+	r.Register(ProviderGemini, func(c ProviderConfig) (Client, error) {
+		return NewGeminiClient(c.APIKey, c.Model, c.BaseURL, c.HTTPClient).WithMaxTokens(c.MaxTokens), nil
+	})
+	r.Register(ProviderMock, func(c ProviderConfig) (Client, error) {
+		return &MockClient{}, nil
+	})
+	return r
+}
+
+// Register adds or replaces a provider
+func (r *Registry) Register(name string, factory ProviderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[name] = factory
+	for cfg := range r.clients {
+		if cfg.Provider == name {
+			delete(r.clients, cfg)
+		}
+	}
+}
+
+// Providers returns the names of the registered providers, sorted
+func (r *Registry) Providers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Client returns the client for cfg, creating it on first use. An empty provider selects the
+// configured one (LLM_PROVIDER); empty settings of the configured provider fall back to the
+// configuration. A client for a caller-supplied API key is created anew on every call so
+// that per-run keys aren't kept beyond the run.
+func (r *Registry) Client(cfg ProviderConfig) (Client, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = r.cfg.Provider
+	}
+	cache := cfg.APIKey == ""
+	if cache {
+		cfg.APIKey = r.keys[cfg.Provider]
+	}
+	if cfg.MaxTokens == 0 {
+		cfg.MaxTokens = r.cfg.MaxTokens
+	}
+	if cfg.Provider == r.cfg.Provider {
+		if cfg.Model == "" {
+			cfg.Model = r.cfg.Model
+		}
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = r.cfg.BaseURL
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.clients[cfg]; ok && cache {
+		return client, nil
+	}
+	factory, ok := r.factories[cfg.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+	client, err := factory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cache {
+		r.clients[cfg] = client
+	}
+	return client, nil
+}
+
+// DefaultProvider returns the name of the configured provider
+func (r *Registry) DefaultProvider() string {
+	return r.cfg.Provider
+}
+
+// Default returns the client of the configured provider
+func (r *Registry) Default() (Client, error) {
+	return r.Client(ProviderConfig{})
+}
@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"workflow-platform/internal/config"
+)
+
+// recordedRequest is a request received by a fake provider server
+type recordedRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// fakeProvider starts a server that answers every request with status and body and records
+// the last request it received
+func fakeProvider(t *testing.T, status int, body string) (*httptest.Server, *recordedRequest) {
+	t.Helper()
+	rec := &recordedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		rec.Path = r.URL.Path
+		rec.Header = r.Header.Clone()
+		rec.Body = nil
+		if err := json.Unmarshal(data, &rec.Body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, rec
+}
+
+// requireStatus checks that err is an *APIError with the given HTTP status
+func requireStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.HTTPStatus() != status {
+		t.Fatalf("expected status %d, got %d (%v)", status, apiErr.HTTPStatus(), err)
+	}
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestRegistryCachesOnlyServerKeys(t *testing.T) {
+	r := NewRegistry(config.LLMConfig{Provider: ProviderAnthropic, APIKey: "server-key", Model: "m"})
+
+	a, err := r.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.Client(ProviderConfig{Provider: ProviderAnthropic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Error("expected the client with the server key to be cached")
+	}
+
+	c, err := r.Client(ProviderConfig{Provider: ProviderAnthropic, APIKey: "user-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := r.Client(ProviderConfig{Provider: ProviderAnthropic, APIKey: "user-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == d {
+		t.Error("expected a new client for each caller-supplied key")
+	}
+	if n := len(r.clients); n != 1 {
+		t.Errorf("expected 1 cached client, got %d", n)
+	}
+}
+
+func TestRegistryUnknownProvider(t *testing.T) {
+	r := NewRegistry(config.LLMConfig{Provider: ProviderOpenAI})
+	if _, err := r.Client(ProviderConfig{Provider: "nope"}); err == nil {
+		t.Fatal("expected an error for an unknown provider")
+	}
+	if _, err := r.Client(ProviderConfig{Provider: ProviderOpenAICompatible}); err == nil {
+		t.Fatal("expected an error for openai_compatible without a base URL")
+	}
+}
+
+func TestRegistryRoutesToBaseURL(t *testing.T) {
+	srv, rec := fakeProvider(t, http.StatusOK, `{"content":[{"type":"text","text":"hi"}]}`)
+	r := NewRegistry(config.LLMConfig{Provider: ProviderOpenAI})
+
+	client, err := r.Client(ProviderConfig{Provider: ProviderAnthropic, BaseURL: srv.URL, APIKey: "k", Model: "claude"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := client.Generate(context.Background(), Request{Prompt: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hi" || rec.Path != "/v1/messages" {
+		t.Errorf("unexpected reply %q from %s", text, rec.Path)
+	}
+}
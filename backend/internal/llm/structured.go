@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonInstruction asks for a reply matching schema, for providers without native structured output
+func jsonInstruction(schema json.RawMessage) string {
+	return "Reply with only a JSON value that matches this JSON Schema, without any other text:\n" + string(schema)
+}
+
+// ParseJSON decodes the JSON value of a reply. Markdown code fences and text around the
+// outermost object or array are ignored, since models add them despite instructions.
+func ParseJSON(text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimPrefix(text, "json")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+		text = strings.TrimSpace(text)
+	}
+
+	var value interface{}
+	err := json.Unmarshal([]byte(text), &value)
+	if err == nil {
+		return value, nil
+	}
+	if start := strings.IndexAny(text, "{["); start >= 0 {
+		end := strings.LastIndexAny(text, "}]")
+		if end > start && json.Unmarshal([]byte(text[start:end+1]), &value) == nil {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("reply is not valid JSON: %w", err)
+}
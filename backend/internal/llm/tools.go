@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// RoleTool tags a message carrying the result of a tool call
+const RoleTool = "tool"
+
+// Tool is a function the model may call instead of answering
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON Schema of the call's arguments
+	Parameters json.RawMessage
+}
+
+// ToolCall is a request of the model to call a tool
+type ToolCall struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Arguments is the JSON object of arguments as sent by the model
+	Arguments string `json:"arguments"`
+}
+
+// Response is a reply that may request tool calls instead of, or next to, text
+type Response struct {
+	Content   string
+	ToolCalls []ToolCall
+}
+
+// ToolClient is implemented by clients whose provider supports tool calling. To continue after
+// tool calls, append the reply as an assistant message with its ToolCalls and one RoleTool
+// message per call with the matching ToolCallID and Name.
+type ToolClient interface {
+	Client
+	GenerateWithTools(ctx context.Context, req Request) (*Response, error)
+}
+
+// toolArguments returns the arguments of a call as a JSON object, since providers reject
+// anything else in the conversation
+func toolArguments(call ToolCall) json.RawMessage {
+	var args map[string]interface{}
+	if json.Unmarshal([]byte(call.Arguments), &args) != nil {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(call.Arguments)
+}
@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"workflow-platform/internal/engine"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func checkpointKey(jobID string) string {
+	return "job:" + jobID + ":checkpoint"
+}
+
+// RedisCheckpointer stores the superstep checkpoints of one job in Redis
+type RedisCheckpointer struct {
+	client *RedisClient
+	jobID  string
+}
+
+// Checkpointer returns an engine.Checkpointer bound to the given job
+func (r *RedisClient) Checkpointer(jobID string) *RedisCheckpointer {
+	return &RedisCheckpointer{client: r, jobID: jobID}
+}
+
+// SaveCheckpoint overwrites the job's checkpoint with cp
+func (c *RedisCheckpointer) SaveCheckpoint(ctx context.Context, cp *engine.Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	return c.client.Client.Set(ctx, checkpointKey(c.jobID), data, jobStateTTL).Err()
+}
+
+// LoadCheckpoint returns the last checkpoint of a job, or nil if it never reached a barrier
+func (r *RedisClient) LoadCheckpoint(ctx context.Context, jobID string) (*engine.Checkpoint, error) {
+	data, err := r.Client.Get(ctx, checkpointKey(jobID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var cp engine.Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// DeleteCheckpoint removes the checkpoint of a finished job
+func (r *RedisClient) DeleteCheckpoint(ctx context.Context, jobID string) error {
+	return r.Client.Del(ctx, checkpointKey(jobID)).Err()
+}
@@ -0,0 +1,278 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"workflow-platform/internal/engine"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// JobStream is the Redis Stream that carries submitted workflow jobs
+	JobStream = "workflow:jobs"
+	// JobGroup is the consumer group shared by all workers
+	JobGroup = "workflow-workers"
+
+	jobStateTTL = 24 * time.Hour
+)
+
+// ErrJobNotFound is returned when no hot state exists for a job
+var ErrJobNotFound = errors.New("job not found")
+
+// JobStatus represents the lifecycle state of a job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job is a unit of work placed on the stream
+type Job struct {
+	ID        string                 `json:"job_id"`
+	Workflow  engine.Workflow        `json:"workflow"`
+	Inputs    map[string]interface{} `json:"inputs,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// Delivery is a job read from the stream together with its stream entry ID
+type Delivery struct {
+	MessageID string
+	Job       Job
+	// Attempts is how many times the entry has been delivered, including this one
+	Attempts int64
+}
+
+// JobState is the hot state of a job kept in Redis while it is queued or running
+type JobState struct {
+	JobID       string                            `json:"job_id"`
+	WorkflowID  string                            `json:"workflow_id"`
+	Status      JobStatus                         `json:"status"`
+	NodeStatus  map[string]engine.ExecutionStatus `json:"node_status,omitempty"`
+	Results     map[string]interface{}            `json:"results,omitempty"`
+	Outputs     map[string]interface{}            `json:"outputs,omitempty"`
+	FailedNodes []string                          `json:"failed_nodes,omitempty"`
+	Error       string                            `json:"error,omitempty"`
+	CreatedAt   time.Time                         `json:"created_at"`
+	StartedAt   *time.Time                        `json:"started_at,omitempty"`
+	CompletedAt *time.Time                        `json:"completed_at,omitempty"`
+	UpdatedAt   time.Time                         `json:"updated_at"`
+}
+
+// NewJobID returns a random (version 4) UUID
+func NewJobID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate job id: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func jobStateKey(jobID string) string {
+	return "job:" + jobID
+}
+
+func jobSecretsKey(jobID string) string {
+	return "job:" + jobID + ":secrets"
+}
+
+func jobCancelKey(jobID string) string {
+	return "job:" + jobID + ":cancel"
+}
+
+// EnqueueJob records the job as queued and appends it to the job stream.
+// Secret config entries of the workflow are kept in a separate key that expires with the
+// job state instead of being written to the stream.
+func (r *RedisClient) EnqueueJob(ctx context.Context, job Job) error {
+	var secrets map[string]string
+	job.Workflow, secrets = engine.SplitSecrets(job.Workflow)
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	state := &JobState{
+		JobID:      job.ID,
+		WorkflowID: job.Workflow.ID,
+		Status:     JobQueued,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.CreatedAt,
+	}
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job state: %w", err)
+	}
+
+	pipe := r.Client.TxPipeline()
+	pipe.Set(ctx, jobStateKey(job.ID), stateJSON, jobStateTTL)
+	if secrets != nil {
+		secretsJSON, err := json.Marshal(secrets)
+		if err != nil {
+			return fmt.Errorf("failed to marshal job secrets: %w", err)
+		}
+		pipe.Set(ctx, jobSecretsKey(job.ID), secretsJSON, jobStateTTL)
+	}
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: JobStream,
+		Values: map[string]interface{}{"job": payload},
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to enqueue job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// EnsureConsumerGroup creates the job stream and worker consumer group if they don't exist yet
+func (r *RedisClient) EnsureConsumerGroup(ctx context.Context) error {
+	err := r.Client.XGroupCreateMkStream(ctx, JobStream, JobGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+	return nil
+}
+
+// ReadJob blocks for up to block waiting for a new job for the given consumer.
+// It returns nil without error when no job arrived in time.
+func (r *RedisClient) ReadJob(ctx context.Context, consumer string, block time.Duration) (*Delivery, error) {
+	streams, err := r.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    JobGroup,
+		Consumer: consumer,
+		Streams:  []string{JobStream, ">"},
+		Count:    1,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			return r.decodeDelivery(ctx, msg, 1)
+		}
+	}
+	return nil, nil
+}
+
+// ClaimStaleJob takes over a job that another consumer received but hasn't acknowledged
+// for at least minIdle, typically because its worker crashed. It returns nil without error
+// when there is nothing to claim.
+func (r *RedisClient) ClaimStaleJob(ctx context.Context, consumer string, minIdle time.Duration) (*Delivery, error) {
+	pending, err := r.Client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: JobStream,
+		Group:  JobGroup,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range pending {
+		// XCLAIM re-checks the idle time, so only one of several racing workers wins
+		msgs, err := r.Client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   JobStream,
+			Group:    JobGroup,
+			Consumer: consumer,
+			MinIdle:  minIdle,
+			Messages: []string{entry.ID},
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			return r.decodeDelivery(ctx, msg, entry.RetryCount+1)
+		}
+	}
+	return nil, nil
+}
+
+func (r *RedisClient) decodeDelivery(ctx context.Context, msg redis.XMessage, attempts int64) (*Delivery, error) {
+	raw, _ := msg.Values["job"].(string)
+	var job Job
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		// A malformed entry can never succeed; drop it so it isn't redelivered forever
+		r.AckJob(ctx, msg.ID)
+		return nil, fmt.Errorf("failed to decode job %s: %w", msg.ID, err)
+	}
+
+	data, err := r.Client.Get(ctx, jobSecretsKey(job.ID)).Bytes()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to load secrets of job %s: %w", job.ID, err)
+	}
+	if data != nil {
+		var secrets map[string]string
+		if err := json.Unmarshal(data, &secrets); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal secrets of job %s: %w", job.ID, err)
+		}
+		job.Workflow = engine.WithSecrets(job.Workflow, secrets)
+	}
+	return &Delivery{MessageID: msg.ID, Job: job, Attempts: attempts}, nil
+}
+
+// AckJob acknowledges a processed stream entry and removes it from the stream
+func (r *RedisClient) AckJob(ctx context.Context, messageID string) error {
+	pipe := r.Client.TxPipeline()
+	pipe.XAck(ctx, JobStream, JobGroup, messageID)
+	pipe.XDel(ctx, JobStream, messageID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteJobSecrets removes the secrets of a finished job
+func (r *RedisClient) DeleteJobSecrets(ctx context.Context, jobID string) error {
+	return r.Client.Del(ctx, jobSecretsKey(jobID)).Err()
+}
+
+// RequestCancel flags a job for cancellation. The worker running it, or the one that
+// receives it next, aborts it.
+func (r *RedisClient) RequestCancel(ctx context.Context, jobID string) error {
+	return r.Client.Set(ctx, jobCancelKey(jobID), 1, jobStateTTL).Err()
+}
+
+// CancelRequested reports whether a job was flagged for cancellation
+func (r *RedisClient) CancelRequested(ctx context.Context, jobID string) (bool, error) {
+	n, err := r.Client.Exists(ctx, jobCancelKey(jobID)).Result()
+	return n > 0, err
+}
+
+// SaveJobState overwrites the hot state of a job
+func (r *RedisClient) SaveJobState(ctx context.Context, state *JobState) error {
+	state.UpdatedAt = time.Now()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job state: %w", err)
+	}
+	return r.Client.Set(ctx, jobStateKey(state.JobID), data, jobStateTTL).Err()
+}
+
+// GetJobState loads the hot state of a job
+func (r *RedisClient) GetJobState(ctx context.Context, jobID string) (*JobState, error) {
+	data, err := r.Client.Get(ctx, jobStateKey(jobID)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrJobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var state JobState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job state: %w", err)
+	}
+	return &state, nil
+}
@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"workflow-platform/internal/llm"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// transcriptTTL is how long an idle chat session is kept
+const transcriptTTL = 7 * 24 * time.Hour
+
+func transcriptKey(session string) string {
+	return "chat:" + session
+}
+
+// LoadTranscript returns the stored transcript of a chat session, or nil if there is none
+func (r *RedisClient) LoadTranscript(ctx context.Context, session string) ([]llm.ChatMessage, error) {
+	data, err := r.Client.Get(ctx, transcriptKey(session)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var transcript []llm.ChatMessage
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transcript: %w", err)
+	}
+	return transcript, nil
+}
+
+// SaveTranscript overwrites the transcript of a chat session
+func (r *RedisClient) SaveTranscript(ctx context.Context, session string, transcript []llm.ChatMessage) error {
+	data, err := json.Marshal(transcript)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript: %w", err)
+	}
+	return r.Client.Set(ctx, transcriptKey(session), data, transcriptTTL).Err()
+}
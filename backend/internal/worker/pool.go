@@ -0,0 +1,268 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"workflow-platform/internal/config"
+	"workflow-platform/internal/db"
+	"workflow-platform/internal/engine"
+	"workflow-platform/internal/queue"
+)
+
+const (
+	readBlock        = 5 * time.Second
+	progressInterval = time.Second
+	stateSaveTimeout = 5 * time.Second
+	// staleGrace is added to the job timeout before an unacknowledged job is
+	// considered abandoned by its worker and claimed by another one.
+	staleGrace = time.Minute
+)
+
+// errJobCancelled is the error recorded for a job cancelled through the API
+var errJobCancelled = errors.New("job cancelled")
+
+// Executor runs a workflow to completion, recording node progress in execCtx.
+// The pool fills in opts.Checkpointer and, for a recovered job, opts.Resume.
+type Executor func(ctx context.Context, wf engine.Workflow, execCtx *engine.ExecutionContext, opts engine.BSPOptions) error
+
+// Pool pulls jobs from the Redis job stream and executes them with a fixed number of workers
+type Pool struct {
+	queue    *queue.RedisClient
+	runs     *db.RunStore
+	cfg      config.WorkerConfig
+	execute  Executor
+	consumer string
+	wg       sync.WaitGroup
+}
+
+// NewPool creates a worker pool. Call Start to begin consuming jobs.
+func NewPool(q *queue.RedisClient, runs *db.RunStore, cfg config.WorkerConfig, execute Executor) *Pool {
+	hostname, _ := os.Hostname()
+	return &Pool{
+		queue:    q,
+		runs:     runs,
+		cfg:      cfg,
+		execute:  execute,
+		consumer: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+// Start joins the consumer group and launches the workers. They stop when ctx is cancelled.
+func (p *Pool) Start(ctx context.Context) error {
+	if err := p.queue.EnsureConsumerGroup(ctx); err != nil {
+		return err
+	}
+
+	numWorkers := p.cfg.NumWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	for i := 0; i < numWorkers; i++ {
+		p.wg.Add(1)
+		go p.worker(ctx, i)
+	}
+
+	log.Printf("Started %d workers (consumer %s)", numWorkers, p.consumer)
+	return nil
+}
+
+// Wait blocks until all workers have exited
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+func (p *Pool) worker(ctx context.Context, id int) {
+	defer p.wg.Done()
+	consumer := fmt.Sprintf("%s-%d", p.consumer, id)
+
+	for ctx.Err() == nil {
+		// Recover jobs abandoned by crashed workers before taking new ones
+		delivery, err := p.queue.ClaimStaleJob(ctx, consumer, p.cfg.JobTimeout+staleGrace)
+		if err == nil && delivery == nil {
+			delivery, err = p.queue.ReadJob(ctx, consumer, readBlock)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Worker %d: failed to read job: %v", id, err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		if delivery == nil {
+			continue
+		}
+
+		if !p.process(ctx, delivery) {
+			// Interrupted by shutdown: leave the job pending so another worker resumes it
+			continue
+		}
+
+		if err := p.queue.AckJob(ctx, delivery.MessageID); err != nil {
+			log.Printf("Worker %d: failed to ack job %s: %v", id, delivery.Job.ID, err)
+		}
+	}
+}
+
+// process runs a single job, keeps its hot state in Redis up to date and persists the run.
+// A redelivered job resumes from its last checkpoint. It returns false if the job was
+// interrupted by shutdown and must not be acknowledged.
+func (p *Pool) process(ctx context.Context, delivery *queue.Delivery) bool {
+	job := delivery.Job
+	log.Printf("Processing job %s (workflow %s, attempt %d)", job.ID, job.Workflow.ID, delivery.Attempts)
+
+	startedAt := time.Now()
+	state := &queue.JobState{
+		JobID:      job.ID,
+		WorkflowID: job.Workflow.ID,
+		Status:     queue.JobRunning,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  &startedAt,
+	}
+
+	opts := engine.BSPOptions{Checkpointer: p.queue.Checkpointer(job.ID)}
+	if delivery.Attempts > 1 {
+		if prev, err := p.queue.GetJobState(ctx, job.ID); err == nil && prev.StartedAt != nil {
+			startedAt = *prev.StartedAt
+		}
+
+		if delivery.Attempts > int64(p.cfg.MaxRetries)+1 {
+			log.Printf("Job %s exceeded %d recovery attempts, giving up", job.ID, p.cfg.MaxRetries)
+			p.finish(job, state, engine.NewExecutionContext(job.Workflow.ID), startedAt,
+				fmt.Errorf("job abandoned after %d attempts", delivery.Attempts-1))
+			return true
+		}
+
+		cp, err := p.queue.LoadCheckpoint(ctx, job.ID)
+		if err != nil {
+			log.Printf("Failed to load checkpoint of job %s, restarting it: %v", job.ID, err)
+		} else if cp != nil {
+			opts.Resume = cp
+		}
+	}
+
+	p.saveState(state)
+	if err := p.runs.StartRun(job.ID, job.Workflow, job.Inputs, string(queue.JobRunning), startedAt); err != nil {
+		log.Printf("Failed to persist start of job %s: %v", job.ID, err)
+	}
+
+	execCtx := engine.NewExecutionContext(job.Workflow.ID)
+	if job.Inputs != nil {
+		execCtx.Inputs = job.Inputs
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, p.cfg.JobTimeout)
+	defer cancel()
+
+	// A job cancelled while it was queued aborts before its first superstep
+	var cancelled atomic.Bool
+	if p.cancelRequested(job.ID) {
+		cancelled.Store(true)
+		cancel()
+	}
+
+	// Publish intermediate node statuses while the run is in progress and watch for cancellation
+	done := make(chan struct{})
+	var progress sync.WaitGroup
+	progress.Add(1)
+	go func() {
+		defer progress.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if !cancelled.Load() && p.cancelRequested(job.ID) {
+					log.Printf("Cancelling job %s", job.ID)
+					cancelled.Store(true)
+					cancel()
+				}
+				snapshot := *state
+				snapshot.NodeStatus, snapshot.Results = execCtx.Snapshot()
+				p.saveState(&snapshot)
+			}
+		}
+	}()
+
+	err := p.execute(runCtx, job.Workflow, execCtx, opts)
+	close(done)
+	progress.Wait()
+
+	if err != nil && ctx.Err() != nil {
+		log.Printf("Job %s interrupted by shutdown, it will resume from its last checkpoint", job.ID)
+		return false
+	}
+	if err != nil && cancelled.Load() {
+		err = fmt.Errorf("%w: %v", errJobCancelled, err)
+	}
+
+	p.finish(job, state, execCtx, startedAt, err)
+	return true
+}
+
+// finish records the final state of a job in Redis and Postgres and drops its checkpoint
+// and secrets
+func (p *Pool) finish(job queue.Job, state *queue.JobState, execCtx *engine.ExecutionContext, startedAt time.Time, err error) {
+	completedAt := time.Now()
+	state.CompletedAt = &completedAt
+	state.NodeStatus, state.Results = execCtx.Snapshot()
+	state.FailedNodes = execCtx.FailedNodes()
+	if err == nil {
+		state.Outputs = execCtx.Outputs
+	}
+	if errors.Is(err, errJobCancelled) {
+		log.Printf("Job %s cancelled", job.ID)
+		state.Status = queue.JobCancelled
+		state.Error = err.Error()
+	} else if err != nil {
+		log.Printf("Job %s failed: %v", job.ID, err)
+		state.Status = queue.JobFailed
+		state.Error = err.Error()
+	} else {
+		log.Printf("Job %s completed in %s", job.ID, completedAt.Sub(startedAt))
+		state.Status = queue.JobCompleted
+	}
+	p.saveState(state)
+
+	if err := p.runs.FinishRun(job.ID, string(state.Status), state.Results, state.Outputs, state.Error, startedAt, completedAt, execCtx.StepHistory()); err != nil {
+		log.Printf("Failed to persist result of job %s: %v", job.ID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateSaveTimeout)
+	defer cancel()
+	if err := p.queue.DeleteCheckpoint(ctx, job.ID); err != nil {
+		log.Printf("Failed to delete checkpoint of job %s: %v", job.ID, err)
+	}
+	if err := p.queue.DeleteJobSecrets(ctx, job.ID); err != nil {
+		log.Printf("Failed to delete secrets of job %s: %v", job.ID, err)
+	}
+}
+
+// cancelRequested reports whether the job was cancelled through the API
+func (p *Pool) cancelRequested(jobID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), stateSaveTimeout)
+	defer cancel()
+	requested, err := p.queue.CancelRequested(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to check cancellation of job %s: %v", jobID, err)
+	}
+	return requested
+}
+
+// saveState writes hot state independently of the worker context so the final
+// status is still recorded when a job is aborted by shutdown.
+func (p *Pool) saveState(state *queue.JobState) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateSaveTimeout)
+	defer cancel()
+	if err := p.queue.SaveJobState(ctx, state); err != nil {
+		log.Printf("Failed to save state for job %s: %v", state.JobID, err)
+	}
+}
@@ -0,0 +1,6 @@
+-- Link runs back to the saved workflow they executed and keep the run-level error
+ALTER TABLE workflow_results ADD COLUMN IF NOT EXISTS workflow_id VARCHAR(255);
+ALTER TABLE workflow_results ADD COLUMN IF NOT EXISTS error TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_workflow_results_workflow_id ON workflow_results(workflow_id, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_execution_history_job_step ON execution_history(job_id, step_number);
@@ -0,0 +1,3 @@
+-- Declared workflow inputs and outputs of each run
+ALTER TABLE workflow_results ADD COLUMN IF NOT EXISTS inputs JSONB;
+ALTER TABLE workflow_results ADD COLUMN IF NOT EXISTS outputs JSONB;
@@ -0,0 +1,2 @@
+-- Number of Compute attempts a node needed, including retries
+ALTER TABLE execution_history ADD COLUMN IF NOT EXISTS attempts INTEGER;
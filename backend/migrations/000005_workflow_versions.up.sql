@@ -0,0 +1,15 @@
+-- Every saved revision of a workflow, so sub-workflow nodes can pin a version
+ALTER TABLE workflows ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS workflow_versions (
+    workflow_id VARCHAR(255) NOT NULL,
+    version INTEGER NOT NULL,
+    definition JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workflow_id, version)
+);
+
+-- Workflows saved before versioning become their version 1
+INSERT INTO workflow_versions (workflow_id, version, definition, created_at)
+SELECT id, version, definition, updated_at FROM workflows
+ON CONFLICT DO NOTHING;
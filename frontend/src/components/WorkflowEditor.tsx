@@ -9,7 +9,7 @@ import ReactFlow, {
 } from 'reactflow';
 import type { Connection, Edge, Node } from 'reactflow';
 import 'reactflow/dist/style.css';
-import type { Workflow } from '../types/workflow';
+import type { EdgeCondition, Workflow } from '../types/workflow';
 import LLMNode from './nodes/LLMNode';
 import ResultNode from './nodes/ResultNode';
 import SettingsModal from './SettingsModal';
@@ -21,11 +21,25 @@ const nodeTypes = {
 };
 
 const initialNodes: Node[] = [
-    { id: '1', position: { x: 250, y: 5 }, data: { label: 'Start', model: 'GPT-4', prompt: 'Hello' }, type: 'LLM' },
+    { id: '1', position: { x: 250, y: 5 }, data: { label: 'Start', model: 'gpt-4o', prompt: 'Hello' }, type: 'LLM' },
     { id: '2', position: { x: 250, y: 200 }, data: { label: 'Result' }, type: 'RESULT' },
 ];
 const initialEdges: Edge[] = [{ id: 'e1-2', source: '1', target: '2' }];
 
+// Jobs are polled until they finish or the deadline passes; workers time out after 5 minutes
+const JOB_POLL_INTERVAL_MS = 1000;
+const JOB_POLL_TIMEOUT_MS = 10 * 60 * 1000;
+
+// wait resolves after ms, or rejects with an AbortError when signal is aborted first
+const wait = (ms: number, signal: AbortSignal) => new Promise<void>((resolve, reject) => {
+    if (signal.aborted) return reject(signal.reason);
+    const timer = setTimeout(resolve, ms);
+    signal.addEventListener('abort', () => {
+        clearTimeout(timer);
+        reject(signal.reason);
+    }, { once: true });
+});
+
 interface WorkflowEditorProps {
     loadedWorkflowId: string | null;
     onWorkflowSaved: () => void;
@@ -40,6 +54,11 @@ export default function WorkflowEditor({ loadedWorkflowId, onWorkflowSaved }: Wo
     const [isJsonViewOpen, setIsJsonViewOpen] = useState(false);
     const [isAddMenuOpen, setIsAddMenuOpen] = useState(false);
     const [workflowName, setWorkflowName] = useState('My Workflow');
+    const pollAbortRef = React.useRef<AbortController | null>(null);
+    const [runningJobId, setRunningJobId] = useState<string | null>(null);
+
+    // Stop polling a running job when the editor unmounts
+    React.useEffect(() => () => pollAbortRef.current?.abort(), []);
 
     // Load workflow when ID changes
     React.useEffect(() => {
@@ -109,7 +128,27 @@ export default function WorkflowEditor({ loadedWorkflowId, onWorkflowSaved }: Wo
 
             if (!response.ok) throw new Error('Failed to save workflow');
 
-            setSuccess('Workflow saved successfully!');
+            const saved = await response.json();
+            const issues: { message: string; node_id?: string }[] = saved.issues || [];
+
+            // Flag the nodes with problems the same way failed executions are shown
+            const nodeIssues = new Map<string, string[]>();
+            for (const issue of issues) {
+                if (issue.node_id) {
+                    nodeIssues.set(issue.node_id, [...(nodeIssues.get(issue.node_id) || []), issue.message]);
+                }
+            }
+            setNodes((nds) => nds.map((node) => ({
+                ...node,
+                data: { ...node.data, error: nodeIssues.get(node.id)?.join('\n') },
+            })));
+
+            if (saved.valid === false || issues.length) {
+                const messages = issues.map((issue) => issue.message);
+                setError(`Workflow saved with problems:\n${messages.join('\n')}`);
+            } else {
+                setSuccess('Workflow saved successfully!');
+            }
             onWorkflowSaved();
         } catch (err) {
             setError((err as Error).message);
@@ -137,6 +176,8 @@ export default function WorkflowEditor({ loadedWorkflowId, onWorkflowSaved }: Wo
                 id: e.id,
                 source: e.source,
                 target: e.target,
+                sourceHandle: e.sourceHandle ?? undefined,
+                condition: e.data?.condition as EdgeCondition | undefined,
             })),
             config: {},
         };
@@ -184,8 +225,39 @@ export default function WorkflowEditor({ loadedWorkflowId, onWorkflowSaved }: Wo
                 throw new Error(`Server error (${response.status}):\n${errorText}`);
             }
 
-            const result = await response.json();
-            console.log('Backend response:', result);
+            const { job_id: jobId } = await response.json();
+            console.log('Queued job:', jobId);
+            setRunningJobId(jobId);
+
+            // Poll the job until a worker has finished it, dropping any earlier poll
+            pollAbortRef.current?.abort();
+            const controller = new AbortController();
+            pollAbortRef.current = controller;
+            const deadline = Date.now() + JOB_POLL_TIMEOUT_MS;
+            let job;
+            for (;;) {
+                await wait(JOB_POLL_INTERVAL_MS, controller.signal);
+                if (Date.now() > deadline) {
+                    throw new Error(`Job ${jobId} did not finish within ${JOB_POLL_TIMEOUT_MS / 60000} minutes`);
+                }
+                const jobResponse = await fetch(`http://localhost:8080/api/jobs?id=${jobId}`, { signal: controller.signal });
+                if (!jobResponse.ok) {
+                    throw new Error(`Server error (${jobResponse.status}):\n${await jobResponse.text()}`);
+                }
+                job = await jobResponse.json();
+                if (job.status === 'completed' || job.status === 'failed' || job.status === 'cancelled') break;
+            }
+            console.log('Backend response:', job);
+            setRunningJobId(null);
+
+            if (job.status === 'failed') {
+                throw new Error(`Workflow execution failed: ${job.error}`);
+            }
+            if (job.status === 'cancelled') {
+                throw new Error('Workflow execution was cancelled');
+            }
+
+            const result = job.results || {};
 
             // Update nodes with results
             setNodes((nds) => nds.map((node) => {
@@ -200,10 +272,26 @@ export default function WorkflowEditor({ loadedWorkflowId, onWorkflowSaved }: Wo
 
             setSuccess('Workflow executed successfully!');
         } catch (err) {
+            // Polling was abandoned because the editor unmounted or a new run started
+            if ((err as Error).name === 'AbortError') return;
+            setRunningJobId(null);
             setError((err as Error).message || 'An unknown error occurred');
         }
     };
 
+    const handleCancel = async () => {
+        if (!runningJobId) return;
+        try {
+            const response = await fetch(`http://localhost:8080/api/jobs?id=${runningJobId}`, { method: 'DELETE' });
+            // 409: the job finished before the request arrived, polling picks up its result
+            if (!response.ok && response.status !== 409) {
+                throw new Error(`Server error (${response.status}):\n${await response.text()}`);
+            }
+        } catch (err) {
+            setError((err as Error).message || 'Failed to cancel the workflow');
+        }
+    };
+
     const addNode = (type: string) => {
         const id = (nodes.length + 1).toString();
         const newNode: Node = {
@@ -263,6 +351,7 @@ export default function WorkflowEditor({ loadedWorkflowId, onWorkflowSaved }: Wo
                 <button onClick={() => setIsSettingsOpen(true)}>Settings</button>
                 <button onClick={() => setIsJsonViewOpen(true)}>Show JSON</button>
                 <button onClick={handleExport}>Run Workflow</button>
+                {runningJobId && <button onClick={handleCancel}>Cancel Run</button>}
             </div>
             <ReactFlow
                 nodes={nodes}
@@ -1,6 +1,16 @@
 import { memo, useState, useCallback } from 'react';
 import { Handle, Position, type NodeProps, useReactFlow } from 'reactflow';
 
+// Model IDs sent to the backend with the provider serving them, so a model runs on its own
+// provider whatever LLM_PROVIDER the server uses. "Server default" leaves both unset.
+const MODEL_OPTIONS = [
+    { value: '', label: 'Server default' },
+    { value: 'gpt-4o', label: 'GPT-4o', provider: 'openai' },
+    { value: 'gpt-4o-mini', label: 'GPT-4o mini', provider: 'openai' },
+    { value: 'claude-sonnet-4-5', label: 'Claude Sonnet 4.5', provider: 'anthropic' },
+    { value: 'gemini-2.5-flash', label: 'Gemini 2.5 Flash', provider: 'gemini' },
+];
+
 const LLMNode = ({ id, data, isConnectable }: NodeProps) => {
     const [expanded, setExpanded] = useState(false);
     const [showDebug, setShowDebug] = useState(false);
@@ -18,10 +28,10 @@ const LLMNode = ({ id, data, isConnectable }: NodeProps) => {
     }, [id, setNodes]);
 
     const handleModelChange = useCallback((evt: React.ChangeEvent<HTMLSelectElement>) => {
-        const newModel = evt.target.value;
+        const option = MODEL_OPTIONS.find((o) => o.value === evt.target.value);
         setNodes((nds) => nds.map((node) => {
             if (node.id === id) {
-                return { ...node, data: { ...node.data, model: newModel } };
+                return { ...node, data: { ...node.data, model: option?.value || undefined, provider: option?.provider } };
             }
             return node;
         }));
@@ -87,7 +97,7 @@ const LLMNode = ({ id, data, isConnectable }: NodeProps) => {
             <div style={{ marginBottom: '16px' }}>
                 <label style={{ display: 'block', fontSize: '12px', fontWeight: '500', marginBottom: '6px', color: '#555' }}>Model</label>
                 <select
-                    value={data.model || 'GPT-4'}
+                    value={MODEL_OPTIONS.some((o) => o.value === data.model) ? data.model : ''}
                     onChange={handleModelChange}
                     style={{
                         width: '100%',
@@ -100,10 +110,9 @@ const LLMNode = ({ id, data, isConnectable }: NodeProps) => {
                     }}
                     onMouseDown={(e) => e.stopPropagation()}
                 >
-                    <option value="GPT-4">GPT-4</option>
-                    <option value="GPT-3.5">GPT-3.5</option>
-                    <option value="Gemini">Gemini</option>
-                    <option value="Claude">Claude</option>
+                    {MODEL_OPTIONS.map((o) => (
+                        <option key={o.value} value={o.value}>{o.label}</option>
+                    ))}
                 </select>
             </div>
 
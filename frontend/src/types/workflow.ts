@@ -12,10 +12,19 @@ export interface Node {
   data: NodeData;
 }
 
+export interface EdgeCondition {
+  field?: string;
+  operator: string;
+  value?: unknown;
+  ignore_case?: boolean;
+}
+
 export interface Edge {
   id: string;
   source: string;
   target: string;
+  sourceHandle?: string;
+  condition?: EdgeCondition;
 }
 
 export interface Workflow {
@@ -23,4 +32,5 @@ export interface Workflow {
   nodes: Node[];
   edges: Edge[];
   config?: Record<string, string>;
+  max_supersteps?: number;
 }